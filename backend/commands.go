@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"questhub/config"
+	"questhub/service"
+)
+
+// runCommand handles admin subcommands ("./main gc-uploads --dry-run").
+// It reports whether a subcommand was run.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "gc-uploads":
+		gcUploads(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		os.Exit(2)
+	}
+	return true
+}
+
+func gcUploads(args []string) {
+	fs := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report orphaned files")
+	grace := fs.Duration("grace", config.UploadGCGrace, "keep unreferenced files younger than this")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	report, err := service.CollectOrphanedUploads(*grace, *dryRun)
+	if err != nil {
+		log.Fatalf("Upload GC failed: %v", err)
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(report)
+		return
+	}
+
+	for _, object := range report.Orphans {
+		fmt.Printf("%s\t%d\t%s\n", object.Key, object.Size, object.LastModified.Format("2006-01-02 15:04"))
+	}
+	if *dryRun {
+		fmt.Printf("%d files scanned, %d orphans (dry run, nothing deleted)\n", report.Scanned, len(report.Orphans))
+	} else {
+		fmt.Printf("%d files scanned, %d orphans deleted, %d bytes freed\n", report.Scanned, report.Deleted, report.FreedBytes)
+	}
+}
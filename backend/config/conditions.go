@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+// ConditionExpiryInterval is how often conditions timed in minutes are checked, 0 disables the job.
+var ConditionExpiryInterval = 30 * time.Second
+
+func loadConditionExpiry() {
+	ConditionExpiryInterval = durationEnv("CONDITION_EXPIRY_INTERVAL", ConditionExpiryInterval)
+}
@@ -8,17 +8,34 @@ import (
 
 var mandatoryEnvVars = []string{
 	"POSTGRES_URL",
-	"BETTER_AUTH_URL",
 }
 
 func InitEnv(filenames ...string) {
 	godotenv.Load(filenames...)
 
 	checkEnv()
+	loadAllowedOrigins()
+	loadRateLimits()
+	loadUploadGC()
+	loadConditionExpiry()
+}
+
+// DevAuthEnabled reports whether the built-in dev identity provider replaces better-auth.
+func DevAuthEnabled() bool {
+	return os.Getenv("DEV_AUTH") == "true"
 }
 
 func checkEnv() {
-	for _, envVar := range mandatoryEnvVars {
+	required := mandatoryEnvVars
+	if DevAuthEnabled() {
+		if IsProduction() {
+			panic("DEV_AUTH cannot be enabled in production")
+		}
+	} else {
+		required = append(required, "BETTER_AUTH_URL")
+	}
+
+	for _, envVar := range required {
 		if value, ok := os.LookupEnv(envVar); !ok {
 			panic(envVar + " is not set in the environment variables")
 		} else if value == "" {
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// AllowedOrigins is shared by the CORS middleware and the websocket upgrader.
+// A single "*" entry allows every origin.
+var AllowedOrigins []string
+
+// Default origins used when ALLOWED_ORIGINS is not set
+var developmentOrigins = []string{
+	"http://localhost:5173",
+	"http://localhost:4173",
+	"http://localhost:3000",
+	"http://127.0.0.1:5173",
+}
+
+// Environment returns APP_ENV, defaulting to "development".
+func Environment() string {
+	env := strings.ToLower(os.Getenv("APP_ENV"))
+	if env == "" {
+		return "development"
+	}
+	return env
+}
+
+func IsProduction() bool {
+	return Environment() == "production"
+}
+
+func loadAllowedOrigins() {
+	AllowedOrigins = nil
+	if raw := os.Getenv("ALLOWED_ORIGINS"); raw != "" {
+		for _, origin := range strings.Split(raw, ",") {
+			origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+			if origin != "" {
+				AllowedOrigins = append(AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if len(AllowedOrigins) == 0 {
+		if IsProduction() {
+			// The frontend serves better-auth, so its origin is the one we trust
+			if authOrigin := originOf(os.Getenv("BETTER_AUTH_URL")); authOrigin != "" {
+				AllowedOrigins = []string{authOrigin}
+			}
+		} else {
+			AllowedOrigins = append([]string{}, developmentOrigins...)
+		}
+	}
+
+	if len(AllowedOrigins) == 0 {
+		if IsProduction() {
+			panic("no allowed origins in production: set ALLOWED_ORIGINS or BETTER_AUTH_URL")
+		}
+		log.Println("WARNING: no allowed origins configured, browser requests from other origins will be rejected")
+	} else if AllowsAllOrigins() {
+		log.Printf("WARNING: ALLOWED_ORIGINS is permissive (*) in %s, any website can call the API and open websockets", Environment())
+	} else {
+		log.Printf("Allowed origins (%s): %s", Environment(), strings.Join(AllowedOrigins, ", "))
+	}
+}
+
+// AllowsAllOrigins reports whether the wildcard origin is configured.
+func AllowsAllOrigins() bool {
+	for _, origin := range AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOriginAllowed checks an Origin header value against the allowlist.
+func IsOriginAllowed(origin string) bool {
+	origin = strings.TrimRight(origin, "/")
+	for _, allowed := range AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
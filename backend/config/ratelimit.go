@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitPolicy allows Limit requests per Window for a single identifier.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimits holds the named policies, defaults overridden by RATE_LIMITS.
+var RateLimits map[string]RateLimitPolicy
+
+var defaultRateLimits = []RateLimitPolicy{
+	{Name: "dice", Limit: 1, Window: 3 * time.Second},
+	{Name: "chat", Limit: 10, Window: 10 * time.Second},
+	{Name: "upload", Limit: 10, Window: time.Minute},
+	{Name: "join", Limit: 5, Window: 10 * time.Minute},
+}
+
+// loadRateLimits reads RATE_LIMITS, formatted as "name=limit/window" pairs
+// separated by commas, e.g. "chat=20/10s,upload=5/1m".
+func loadRateLimits() {
+	RateLimits = make(map[string]RateLimitPolicy)
+	for _, policy := range defaultRateLimits {
+		RateLimits[policy.Name] = policy
+	}
+
+	raw := os.Getenv("RATE_LIMITS")
+	if raw == "" {
+		return
+	}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		policy, err := parseRateLimitPolicy(entry)
+		if err != nil {
+			panic("invalid RATE_LIMITS entry " + entry + ": " + err.Error())
+		}
+		RateLimits[policy.Name] = policy
+		log.Printf("Rate limit policy %s: %d per %s", policy.Name, policy.Limit, policy.Window)
+	}
+}
+
+func parseRateLimitPolicy(entry string) (RateLimitPolicy, error) {
+	name, spec, ok := strings.Cut(entry, "=")
+	if !ok || name == "" {
+		return RateLimitPolicy{}, fmt.Errorf("expected name=limit/window")
+	}
+
+	limitStr, windowStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimitPolicy{}, fmt.Errorf("expected limit/window")
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("limit must be a positive integer")
+	}
+
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("window must be a positive duration")
+	}
+
+	return RateLimitPolicy{Name: strings.TrimSpace(name), Limit: limit, Window: window}, nil
+}
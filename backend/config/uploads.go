@@ -0,0 +1,65 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UploadQuota is the number of bytes each user may store, 0 means unlimited.
+var UploadQuota int64 = 100 << 20
+
+// UploadGCInterval is how often orphaned uploads are collected, 0 disables the job.
+var UploadGCInterval = 24 * time.Hour
+
+// UploadGCGrace protects recent uploads that may not be referenced yet
+// (e.g. an image uploaded while a form is still being filled).
+var UploadGCGrace = 72 * time.Hour
+
+// loadUploadGC reads UPLOAD_GC_INTERVAL and UPLOAD_GC_GRACE as Go durations ("12h", "30m").
+func loadUploadGC() {
+	UploadGCInterval = durationEnv("UPLOAD_GC_INTERVAL", UploadGCInterval)
+	UploadGCGrace = durationEnv("UPLOAD_GC_GRACE", UploadGCGrace)
+
+	if raw := os.Getenv("UPLOAD_QUOTA"); raw != "" {
+		quota, err := parseSize(raw)
+		if err != nil {
+			log.Printf("WARNING: invalid UPLOAD_QUOTA %q, using %d bytes", raw, UploadQuota)
+		} else {
+			UploadQuota = quota
+		}
+	}
+}
+
+// parseSize reads a byte count with an optional KB, MB or GB suffix ("250MB").
+func parseSize(raw string) (int64, error) {
+	raw = strings.ToUpper(strings.TrimSpace(raw))
+	multiplier := int64(1)
+	for suffix, value := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(raw, suffix) {
+			multiplier = value
+			raw = strings.TrimSpace(strings.TrimSuffix(raw, suffix))
+			break
+		}
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return value * multiplier, nil
+}
+
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value < 0 {
+		log.Printf("WARNING: invalid %s %q, using %s", name, raw, fallback)
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net/http"
+	"questhub/service"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+func GetAPITokens(c echo.Context) error {
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	tokens, err := service.GetUserAPITokens(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch tokens").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func CreateAPIToken(c echo.Context) error {
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"` // Optional, never expires if omitted
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !service.IsValidScope(scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid scope: "+scope)
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	token, plain, err := service.CreateAPIToken(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create token").SetInternal(err)
+	}
+
+	// The plain token is only ever shown in this response
+	return c.JSON(http.StatusCreated, map[string]any{
+		"token":   plain,
+		"details": token,
+	})
+}
+
+func RevokeAPIToken(c echo.Context) error {
+	tokenID := c.Param("tokenId")
+	if tokenID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing token ID")
+	}
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if err := service.RevokeAPIToken(tokenID, userID); err != nil {
+		if err.Error() == "token not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke token").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Token revoked"})
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"questhub/service"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// recordAudit logs a GM action on behalf of the current user. Failures are
+// logged but never fail the request that already succeeded.
+func recordAudit(c echo.Context, gameID, action, targetType, targetID string, before, after any) {
+	claims := c.Get("claims").(jwt.MapClaims)
+	actorID := claims["sub"].(string)
+
+	if err := service.RecordAudit(gameID, actorID, action, targetType, targetID, before, after); err != nil {
+		fmt.Printf("Failed to record audit entry %s for game %s: %v\n", action, gameID, err)
+	}
+}
+
+func GetAuditLog(c echo.Context) error {
+	gameID := c.Param("id")
+	if gameID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing game ID")
+	}
+
+	// Verify GM - Handled by middleware
+
+	filter := service.AuditFilter{
+		ActorID: c.QueryParam("actor"),
+		Action:  c.QueryParam("action"),
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		filter.Limit = limit
+	}
+	if raw := c.QueryParam("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before, expected RFC3339 date")
+		}
+		filter.Before = &before
+	}
+
+	entries, err := service.GetAuditLog(gameID, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch audit log").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	"questhub/service"
+	"questhub/websocket"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+func GetGameBans(c echo.Context) error {
+	gameID := c.Param("id")
+	if gameID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing game ID")
+	}
+
+	// Verify GM - Handled by middleware
+
+	bans, err := service.GetGameBans(gameID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch bans").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, bans)
+}
+
+func BanPlayer(c echo.Context) error {
+	gameID := c.Param("id")
+	targetUserID := c.Param("userId")
+	if gameID == "" || targetUserID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing game ID or user ID")
+	}
+
+	var req struct {
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expires_at"` // Optional, permanent if omitted
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+
+	// Verify GM - Handled by middleware
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+	}
+
+	if targetUserID == game.GmID {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot ban the Game Master")
+	}
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	gmID := claims["sub"].(string)
+
+	if err := service.BanPlayer(gameID, targetUserID, gmID, req.Reason, req.ExpiresAt); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to ban player").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditPlayerBan, "player", targetUserID, nil, req)
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.KickFromGame(gameID, targetUserID, "banned")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Player banned successfully"})
+}
+
+func UnbanPlayer(c echo.Context) error {
+	gameID := c.Param("id")
+	targetUserID := c.Param("userId")
+	if gameID == "" || targetUserID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing game ID or user ID")
+	}
+
+	// Verify GM - Handled by middleware
+
+	if err := service.UnbanPlayer(gameID, targetUserID); err != nil {
+		if err.Error() == "ban not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Ban not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unban player").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditPlayerUnban, "player", targetUserID, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Player unbanned successfully"})
+}
@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastBattleMap sends the current state of a map to the game, filtered per member.
+func broadcastBattleMap(gameID string, battleMap *model.BattleMap) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		fmt.Printf("Error fetching game for map broadcast: %v\n", err)
+		return
+	}
+	websocket.GlobalHub.BroadcastBattleMap(gameID, game.GmID, battleMap)
+}
+
+// broadcastTokenChange notifies the members who can see the token, before or after the change.
+func broadcastTokenChange(gameID, mapID, msgType string, token, previous *model.MapToken) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		fmt.Printf("Error fetching game for token broadcast: %v\n", err)
+		return
+	}
+	battleMap, err := service.GetBattleMap(gameID, mapID)
+	if err != nil {
+		fmt.Printf("Error fetching map for token broadcast: %v\n", err)
+		return
+	}
+	websocket.GlobalHub.BroadcastTokenChange(gameID, game.GmID, battleMap, msgType, token, previous)
+}
+
+func GetBattleMaps(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Players only see the map the GM has made active
+	maps, err := service.GetBattleMaps(gameID, !isGM)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch maps").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, maps)
+}
+
+func GetBattleMap(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	battleMap, err := service.GetBattleMap(gameID, mapID)
+	if err != nil {
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch map").SetInternal(err)
+	}
+
+	if !isGM {
+		// Hidden tokens and tokens under the fog are never sent to players
+		battleMap = service.BattleMapForPlayer(battleMap, userID)
+		if battleMap == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+	}
+
+	return c.JSON(http.StatusOK, battleMap)
+}
+
+func CreateBattleMap(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.BattleMapInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	battleMap, err := service.CreateBattleMap(gameID, userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMap) || errors.Is(err, service.ErrUploadNotOwned) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create map").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditMapCreate, "map", battleMap.ID, nil, req)
+
+	broadcastBattleMap(gameID, battleMap)
+
+	return c.JSON(http.StatusCreated, battleMap)
+}
+
+func UpdateBattleMap(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.BattleMapInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetBattleMap(gameID, mapID)
+	if err != nil {
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch map").SetInternal(err)
+	}
+
+	battleMap, err := service.UpdateBattleMap(gameID, mapID, userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMap) || errors.Is(err, service.ErrUploadNotOwned) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update map").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditMapUpdate, "map", mapID, before, req)
+
+	broadcastBattleMap(gameID, battleMap)
+
+	return c.JSON(http.StatusOK, battleMap)
+}
+
+func DeleteBattleMap(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetBattleMap(gameID, mapID)
+	if err != nil {
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch map").SetInternal(err)
+	}
+	if err := service.DeleteBattleMap(gameID, mapID); err != nil {
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete map").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditMapDelete, "map", mapID, before, nil)
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+			"type":    "MAP_DELETED",
+			"game_id": gameID,
+			"payload": map[string]string{"map_id": mapID},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Map deleted"})
+}
+
+func AddMapToken(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+
+	var req service.MapTokenInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	token, err := service.AddMapToken(gameID, mapID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTokenOutOfBounds):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case err.Error() == "map not found":
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		case err.Error() == "character not found":
+			return echo.NewHTTPError(http.StatusBadRequest, "Character is not part of this game")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to add token").SetInternal(err)
+		}
+	}
+
+	recordAudit(c, gameID, service.AuditMapTokenAdd, "map_token", token.ID, nil, token)
+
+	broadcastTokenChange(gameID, mapID, "TOKEN_ADDED", token, nil)
+
+	return c.JSON(http.StatusCreated, token)
+}
+
+func RemoveMapToken(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+	tokenID := c.Param("tokenId")
+
+	// Verify GM - Handled by middleware
+	removed, err := service.RemoveMapToken(gameID, mapID, tokenID)
+	if err != nil {
+		if err.Error() == "token not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove token").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditMapTokenRemove, "map_token", tokenID, removed, nil)
+
+	broadcastTokenChange(gameID, removed.MapID, "TOKEN_REMOVED", nil, removed)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Token removed"})
+}
+
+// MoveMapToken is the HTTP counterpart of the TOKEN_MOVE websocket frame.
+func MoveMapToken(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+	tokenID := c.Param("tokenId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	token, previous, err := service.MoveToken(gameID, mapID, tokenID, userID, req.X, req.Y)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTokenForbidden):
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case errors.Is(err, service.ErrTokenOutOfBounds):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case err.Error() == "token not found":
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to move token").SetInternal(err)
+		}
+	}
+
+	broadcastTokenChange(gameID, mapID, "TOKEN_MOVED", token, previous)
+
+	return c.JSON(http.StatusOK, token)
+}
+
+func UpdateMapToken(c echo.Context) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+	tokenID := c.Param("tokenId")
+
+	var req service.MapTokenInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	token, previous, err := service.UpdateMapToken(gameID, mapID, tokenID, req)
+	if err != nil {
+		if err.Error() == "token not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update token").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditMapTokenUpdate, "map_token", tokenID, previous, token)
+
+	broadcastTokenChange(gameID, token.MapID, "TOKEN_UPDATED", token, previous)
+
+	return c.JSON(http.StatusOK, token)
+}
+
+// updateFog handles both fog endpoints, the body is a FogArea.
+func updateFog(c echo.Context, reveal bool) error {
+	gameID := c.Param("id")
+	mapID := c.Param("mapId")
+
+	var req service.FogArea
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	battleMap, err := service.UpdateFog(gameID, mapID, req, reveal)
+	if err != nil {
+		if errors.Is(err, service.ErrTokenOutOfBounds) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid fog area")
+		}
+		if err.Error() == "map not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Map not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update fog").SetInternal(err)
+	}
+
+	action := service.AuditMapFogHide
+	if reveal {
+		action = service.AuditMapFogReveal
+	}
+	recordAudit(c, gameID, action, "map", mapID, nil, req)
+
+	// Revealing or hiding cells changes which tokens players see, resend the whole map
+	broadcastBattleMap(gameID, battleMap)
+
+	return c.JSON(http.StatusOK, battleMap)
+}
+
+func RevealFog(c echo.Context) error {
+	return updateFog(c, true)
+}
+
+func HideFog(c echo.Context) error {
+	return updateFog(c, false)
+}
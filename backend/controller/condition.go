@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastConditions sends the conditions of a character to the GM and its player.
+func broadcastConditions(gameID, characterID string) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	websocket.GlobalHub.BroadcastCharacterConditions(gameID, characterID)
+}
+
+// broadcastCombatRound sends the new round to the game, then the conditions
+// that ended with it.
+func broadcastCombatRound(gameID string, round int, expired []model.Condition) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+		"type":    "COMBAT_ROUND",
+		"game_id": gameID,
+		"payload": map[string]int{"round": round},
+	})
+	websocket.GlobalHub.BroadcastExpiredConditions(expired)
+}
+
+func ApplyCondition(c echo.Context) error {
+	gameID := c.Param("id")
+	charID := c.Param("charId")
+
+	var req service.ConditionInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	condition, err := service.ApplyCondition(gameID, charID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCondition) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err.Error() == "character not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Character not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply condition").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditConditionApply, "character", charID, nil, condition)
+
+	broadcastConditions(gameID, charID)
+
+	return c.JSON(http.StatusCreated, condition)
+}
+
+func RemoveCondition(c echo.Context) error {
+	gameID := c.Param("id")
+	charID := c.Param("charId")
+	conditionID := c.Param("conditionId")
+
+	// Verify GM - Handled by middleware
+	if err := service.RemoveCondition(gameID, charID, conditionID); err != nil {
+		if err.Error() == "condition not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Condition not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove condition").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditConditionRemove, "character", charID,
+		map[string]string{"condition_id": conditionID}, nil)
+
+	broadcastConditions(gameID, charID)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Condition removed"})
+}
+
+func GetCombatRound(c echo.Context) error {
+	gameID := c.Param("id")
+
+	// Verify GM - Handled by middleware
+	round, err := service.GetCombatRound(gameID)
+	if err != nil {
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch combat round").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"round": round})
+}
+
+// NextCombatRound advances combat by one round, ending the conditions that run out.
+func NextCombatRound(c echo.Context) error {
+	gameID := c.Param("id")
+
+	// Verify GM - Handled by middleware
+	round, expired, err := service.AdvanceCombatRound(gameID)
+	if err != nil {
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to advance combat round").SetInternal(err)
+	}
+
+	// Expired conditions are deleted, keep them in the log
+	recordAudit(c, gameID, service.AuditCombatRound, "game", gameID,
+		map[string]any{"round": round - 1, "expired_conditions": expired},
+		map[string]int{"round": round})
+
+	broadcastCombatRound(gameID, round, expired)
+
+	return c.JSON(http.StatusOK, map[string]any{"round": round, "expired": expired})
+}
+
+// EndCombat resets the round counter and removes conditions counted in rounds.
+func EndCombat(c echo.Context) error {
+	gameID := c.Param("id")
+
+	// Verify GM - Handled by middleware
+	round, err := service.GetCombatRound(gameID)
+	if err != nil {
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch combat round").SetInternal(err)
+	}
+
+	expired, err := service.EndCombat(gameID)
+	if err != nil {
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to end combat").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditCombatEnd, "game", gameID,
+		map[string]any{"round": round, "expired_conditions": expired},
+		map[string]int{"round": 0})
+
+	broadcastCombatRound(gameID, 0, expired)
+
+	return c.JSON(http.StatusOK, map[string]any{"round": 0, "expired": expired})
+}
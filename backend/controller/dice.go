@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"questhub/middleware"
 	"questhub/models/database"
 	"questhub/service"
 	"questhub/websocket"
@@ -95,6 +96,7 @@ func RollDice(c echo.Context) error {
 		Type:       msgType,
 		TargetID:   targetID,
 		CreatedAt:  time.Now(),
+		IsBot:      ok && middleware.IsAPIToken(claims),
 	}
 
 	// 5. Persist Message
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"questhub/imaging"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+func GetHandouts(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	// The GM sees every handout, players only those revealed to them
+	if isGM {
+		handouts, err := service.GetGameHandouts(gameID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch handouts").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, handouts)
+	}
+
+	handouts, err := service.GetPlayerHandouts(gameID, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch handouts").SetInternal(err)
+	}
+	return c.JSON(http.StatusOK, handouts)
+}
+
+func CreateHandout(c echo.Context) error {
+	gameID := c.Param("id")
+
+	// Verify GM - Handled by middleware
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	title := c.FormValue("title")
+	if title == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Title is required")
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "No file uploaded")
+	}
+	if file.Size > imaging.MaxUploadSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file").SetInternal(err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, imaging.MaxUploadSize+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file").SetInternal(err)
+	}
+
+	handout, err := service.CreateHandout(gameID, userID, title, c.FormValue("description"), file.Filename, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
+		case errors.Is(err, service.ErrQuotaExceeded):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Storage quota exceeded")
+		case errors.Is(err, service.ErrHandoutType), errors.Is(err, imaging.ErrTooManyPixels):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create handout").SetInternal(err)
+		}
+	}
+
+	recordAudit(c, gameID, service.AuditHandoutCreate, "handout", handout.ID, nil, handout)
+
+	return c.JSON(http.StatusCreated, handout)
+}
+
+func RevealHandout(c echo.Context) error {
+	gameID := c.Param("id")
+	handoutID := c.Param("handoutId")
+
+	var req struct {
+		UserIDs []string `json:"user_ids"` // Empty reveals to every player
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	handout, revealedTo, err := service.RevealHandout(gameID, handoutID, req.UserIDs)
+	if err != nil {
+		if err.Error() == "handout not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Handout not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reveal handout").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditHandoutReveal, "handout", handoutID, nil, req)
+
+	if websocket.GlobalHub != nil {
+		msg := map[string]any{
+			"type":    "HANDOUT_REVEALED",
+			"game_id": gameID,
+			"payload": handout,
+		}
+		if len(req.UserIDs) == 0 {
+			websocket.GlobalHub.BroadcastToGame(gameID, msg)
+		} else {
+			// Only the players who actually received it
+			msgBytes, _ := json.Marshal(msg)
+			for _, userID := range revealedTo {
+				websocket.GlobalHub.SendToUser(userID, msgBytes)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, handout)
+}
+
+func HideHandout(c echo.Context) error {
+	gameID := c.Param("id")
+	handoutID := c.Param("handoutId")
+
+	// Verify GM - Handled by middleware
+	if err := service.HideHandout(gameID, handoutID); err != nil {
+		if err.Error() == "handout not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Handout not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hide handout").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditHandoutHide, "handout", handoutID, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Handout hidden"})
+}
+
+func DeleteHandout(c echo.Context) error {
+	gameID := c.Param("id")
+	handoutID := c.Param("handoutId")
+
+	// Verify GM - Handled by middleware
+	before, _ := service.GetHandout(gameID, handoutID)
+
+	if err := service.DeleteHandout(gameID, handoutID); err != nil {
+		if err.Error() == "handout not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Handout not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete handout").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditHandoutDelete, "handout", handoutID, before, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Handout deleted"})
+}
+
+// GetHandoutFile streams a handout to the GM or to players it was revealed to.
+// Hidden handouts answer 404 so players cannot probe for them.
+func GetHandoutFile(c echo.Context) error {
+	gameID := c.Param("id")
+	handoutID := c.Param("handoutId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	handout, err := service.GetHandout(gameID, handoutID)
+	if err != nil {
+		if err.Error() == "handout not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Handout not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch handout").SetInternal(err)
+	}
+
+	if !isGM {
+		allowed, err := service.CanViewHandout(handoutID, userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check handout access").SetInternal(err)
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusNotFound, "Handout not found")
+		}
+	}
+
+	file, err := service.OpenHandoutFile(handout)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Handout file not found").SetInternal(err)
+	}
+	defer file.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, "inline; filename="+strconv.Quote(handout.FileName))
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, handout.ContentType, file)
+}
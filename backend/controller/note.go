@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastNote sends the note to the members who can read it, and removes
+// it for those who could only read the previous version.
+func broadcastNote(gameID string, note, previous *model.Note) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		fmt.Printf("Error fetching game for note broadcast: %v\n", err)
+		return
+	}
+	websocket.GlobalHub.BroadcastNote(gameID, game.GmID, note, previous)
+}
+
+func noteError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidNote), errors.Is(err, service.ErrUnknownReader):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrNotePrivate):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case err.Error() == "note not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Note not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback).SetInternal(err)
+}
+
+// requireNoteAuthor loads a note only its author may change.
+func requireNoteAuthor(gameID, noteID, userID string) (*model.Note, error) {
+	note, err := service.GetNote(gameID, noteID)
+	if err != nil {
+		return nil, noteError(err, "Failed to fetch note")
+	}
+	if note.UserID != userID {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "You can only change your own notes")
+	}
+	return note, nil
+}
+
+// GetNotes lists the notes the user can read, optionally in a folder or with a tag.
+func GetNotes(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	filter := service.NoteFilter{Folder: c.QueryParam("folder"), Tag: c.QueryParam("tag")}
+	notes, err := service.GetVisibleNotes(gameID, userID, isGM, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notes").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, notes)
+}
+
+func GetNote(c echo.Context) error {
+	gameID := c.Param("id")
+	noteID := c.Param("noteId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	note, err := service.GetNote(gameID, noteID)
+	if err != nil {
+		return noteError(err, "Failed to fetch note")
+	}
+	if !service.CanReadNote(note, userID, isGM) {
+		return echo.NewHTTPError(http.StatusNotFound, "Note not found")
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+func CreateNote(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	var req service.NoteInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	note, err := service.CreateNote(gameID, userID, req)
+	if err != nil {
+		return noteError(err, "Failed to create note")
+	}
+
+	broadcastNote(gameID, note, nil)
+
+	return c.JSON(http.StatusCreated, note)
+}
+
+func UpdateNote(c echo.Context) error {
+	gameID := c.Param("id")
+	noteID := c.Param("noteId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+	previous, err := requireNoteAuthor(gameID, noteID, userID)
+	if err != nil {
+		return err
+	}
+
+	var req service.NoteInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	note, err := service.UpdateNote(gameID, noteID, req)
+	if err != nil {
+		return noteError(err, "Failed to update note")
+	}
+
+	broadcastNote(gameID, note, previous)
+
+	return c.JSON(http.StatusOK, note)
+}
+
+func DeleteNote(c echo.Context) error {
+	gameID := c.Param("id")
+	noteID := c.Param("noteId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+	if _, err := requireNoteAuthor(gameID, noteID, userID); err != nil {
+		return err
+	}
+
+	if err := service.DeleteNote(gameID, noteID); err != nil {
+		return noteError(err, "Failed to delete note")
+	}
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+			"type":    "NOTE_DELETED",
+			"game_id": gameID,
+			"payload": map[string]string{"note_id": noteID},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Note deleted"})
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastQuest sends the quest to the GM, and to players if it is revealed.
+func broadcastQuest(gameID string, quest *model.Quest) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		fmt.Printf("Error fetching game for quest broadcast: %v\n", err)
+		return
+	}
+	websocket.GlobalHub.BroadcastQuest(gameID, game.GmID, quest)
+}
+
+// broadcastCharacterUpdate sends the new state of a character to its owner.
+func broadcastCharacterUpdate(gameID, charID string) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	char, err := service.GetCharacter(gameID, charID)
+	if err != nil || char == nil || char.UserID == nil {
+		return
+	}
+	msgBytes, _ := json.Marshal(map[string]any{
+		"type":    "CHARACTER_UPDATE",
+		"payload": char,
+	})
+	websocket.GlobalHub.SendToUser(*char.UserID, msgBytes)
+}
+
+func questError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidQuest), errors.Is(err, service.ErrInvalidQuestStatus),
+		errors.Is(err, service.ErrInvalidItems), errors.Is(err, service.ErrUnknownCharacter),
+		errors.Is(err, service.ErrUploadNotOwned):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case err.Error() == "quest not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Quest not found")
+	case err.Error() == "objective not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Objective not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback).SetInternal(err)
+}
+
+func GetQuests(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Players only see the quests the GM revealed
+	quests, err := service.GetQuests(gameID, !isGM)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch quests").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, quests)
+}
+
+func GetQuest(c echo.Context) error {
+	gameID := c.Param("id")
+	questID := c.Param("questId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	quest, err := service.GetQuest(gameID, questID)
+	if err != nil {
+		return questError(err, "Failed to fetch quest")
+	}
+	if quest.Hidden && !isGM {
+		return echo.NewHTTPError(http.StatusNotFound, "Quest not found")
+	}
+
+	return c.JSON(http.StatusOK, quest)
+}
+
+func CreateQuest(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.QuestInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	quest, err := service.CreateQuest(gameID, userID, req)
+	if err != nil {
+		return questError(err, "Failed to create quest")
+	}
+
+	recordAudit(c, gameID, service.AuditQuestCreate, "quest", quest.ID, nil, quest)
+
+	broadcastQuest(gameID, quest)
+
+	return c.JSON(http.StatusCreated, quest)
+}
+
+func UpdateQuest(c echo.Context) error {
+	gameID := c.Param("id")
+	questID := c.Param("questId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.QuestInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetQuest(gameID, questID)
+	if err != nil {
+		return questError(err, "Failed to fetch quest")
+	}
+
+	quest, err := service.UpdateQuest(gameID, questID, userID, req)
+	if err != nil {
+		return questError(err, "Failed to update quest")
+	}
+
+	recordAudit(c, gameID, service.AuditQuestUpdate, "quest", questID, before, quest)
+
+	broadcastQuest(gameID, quest)
+
+	return c.JSON(http.StatusOK, quest)
+}
+
+// UpdateQuestStatus changes the status of a quest. Completing it gives the
+// rewards to the assigned characters, once.
+func UpdateQuestStatus(c echo.Context) error {
+	gameID := c.Param("id")
+	questID := c.Param("questId")
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetQuest(gameID, questID)
+	if err != nil {
+		return questError(err, "Failed to fetch quest")
+	}
+
+	// Levels before the rewards, to announce level ups
+	levels := map[string]int{}
+	for _, charID := range before.CharacterIDs {
+		if char, err := service.GetCharacter(gameID, charID); err == nil && char != nil {
+			levels[charID] = char.Level
+		}
+	}
+
+	quest, rewarded, err := service.SetQuestStatus(gameID, questID, req.Status)
+	if err != nil {
+		return questError(err, "Failed to update quest status")
+	}
+
+	recordAudit(c, gameID, service.AuditQuestStatus, "quest", questID,
+		map[string]string{"status": before.Status},
+		map[string]any{"status": quest.Status, "rewarded_characters": rewarded})
+
+	broadcastQuest(gameID, quest)
+	for _, charID := range rewarded {
+		broadcastCharacterUpdate(gameID, charID)
+		if char, err := service.GetCharacter(gameID, charID); err == nil && char != nil {
+			if previous, ok := levels[charID]; ok {
+				announceLevelUp(gameID, charID, char.Name, previous, char.Level)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, quest)
+}
+
+// findObjective returns the objective of the quest with the given ID, or nil.
+func findObjective(quest *model.Quest, objectiveID string) *model.QuestObjective {
+	for i := range quest.Objectives {
+		if quest.Objectives[i].ID == objectiveID {
+			return &quest.Objectives[i]
+		}
+	}
+	return nil
+}
+
+func UpdateQuestObjective(c echo.Context) error {
+	gameID := c.Param("id")
+	questID := c.Param("questId")
+	objectiveID := c.Param("objectiveId")
+
+	var req struct {
+		Completed bool `json:"completed"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetQuest(gameID, questID)
+	if err != nil {
+		return questError(err, "Failed to fetch quest")
+	}
+
+	quest, err := service.SetObjectiveCompleted(gameID, questID, objectiveID, req.Completed)
+	if err != nil {
+		return questError(err, "Failed to update objective")
+	}
+
+	recordAudit(c, gameID, service.AuditQuestObjective, "quest_objective", objectiveID,
+		findObjective(before, objectiveID), findObjective(quest, objectiveID))
+
+	broadcastQuest(gameID, quest)
+
+	return c.JSON(http.StatusOK, quest)
+}
+
+func DeleteQuest(c echo.Context) error {
+	gameID := c.Param("id")
+	questID := c.Param("questId")
+
+	// Verify GM - Handled by middleware
+	quest, err := service.DeleteQuest(gameID, questID)
+	if err != nil {
+		return questError(err, "Failed to delete quest")
+	}
+
+	recordAudit(c, gameID, service.AuditQuestDelete, "quest", questID, quest, nil)
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+			"type":    "QUEST_DELETED",
+			"game_id": gameID,
+			"payload": map[string]string{"quest_id": questID},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Quest deleted"})
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+func randomTableError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidRandomTable), errors.Is(err, service.ErrInvalidDice),
+		errors.Is(err, service.ErrInvalidRange), errors.Is(err, service.ErrInvalidTableRef),
+		errors.Is(err, service.ErrInvalidItems), errors.Is(err, service.ErrRandomTableDepth),
+		errors.Is(err, service.ErrRandomTableCycle), errors.Is(err, service.ErrUploadNotOwned):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case err.Error() == "random table not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Random table not found")
+	case err.Error() == "character not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Character not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback).SetInternal(err)
+}
+
+func GetRandomTables(c echo.Context) error {
+	gameID := c.Param("id")
+
+	// Verify GM - Handled by middleware
+	tables, err := service.GetRandomTables(gameID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch random tables").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, tables)
+}
+
+// GetSharedRandomTables lists the tables any GM can import.
+func GetSharedRandomTables(c echo.Context) error {
+	tables, err := service.GetSharedRandomTables()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch shared random tables").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, tables)
+}
+
+func GetRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	tableID := c.Param("tableId")
+
+	// Verify GM - Handled by middleware
+	table, err := service.GetRandomTable(gameID, tableID)
+	if err != nil {
+		return randomTableError(err, "Failed to fetch random table")
+	}
+
+	return c.JSON(http.StatusOK, table)
+}
+
+func CreateRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.RandomTableInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	table, err := service.CreateRandomTable(gameID, userID, req)
+	if err != nil {
+		return randomTableError(err, "Failed to create random table")
+	}
+
+	recordAudit(c, gameID, service.AuditRandomTableCreate, "random_table", table.ID, nil, table)
+
+	return c.JSON(http.StatusCreated, table)
+}
+
+// ImportRandomTable copies a shared table into the game.
+func ImportRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req struct {
+		TableID string `json:"table_id"`
+	}
+	if err := c.Bind(&req); err != nil || req.TableID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	table, err := service.ImportRandomTable(gameID, req.TableID, userID)
+	if err != nil {
+		return randomTableError(err, "Failed to import random table")
+	}
+
+	recordAudit(c, gameID, service.AuditRandomTableImport, "random_table", table.ID,
+		map[string]string{"source_table_id": req.TableID}, table)
+
+	return c.JSON(http.StatusCreated, table)
+}
+
+func UpdateRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	tableID := c.Param("tableId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.RandomTableInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetRandomTable(gameID, tableID)
+	if err != nil {
+		return randomTableError(err, "Failed to fetch random table")
+	}
+
+	table, err := service.UpdateRandomTable(gameID, tableID, userID, req)
+	if err != nil {
+		return randomTableError(err, "Failed to update random table")
+	}
+
+	recordAudit(c, gameID, service.AuditRandomTableUpdate, "random_table", tableID, before, table)
+
+	return c.JSON(http.StatusOK, table)
+}
+
+func DeleteRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	tableID := c.Param("tableId")
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetRandomTable(gameID, tableID)
+	if err != nil {
+		return randomTableError(err, "Failed to fetch random table")
+	}
+
+	if err := service.DeleteRandomTable(gameID, tableID); err != nil {
+		return randomTableError(err, "Failed to delete random table")
+	}
+
+	recordAudit(c, gameID, service.AuditRandomTableDelete, "random_table", tableID, before, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Random table deleted"})
+}
+
+// RollRandomTable rolls on a table and posts the outcome in chat, privately
+// to the GM when secret is set. With character_id the loot items rolled are
+// added to that character's inventory.
+func RollRandomTable(c echo.Context) error {
+	gameID := c.Param("id")
+	tableID := c.Param("tableId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req struct {
+		Secret      bool   `json:"secret"`
+		CharacterID string `json:"character_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	roll, err := service.RollRandomTable(gameID, tableID)
+	if err != nil {
+		return randomTableError(err, "Failed to roll on random table")
+	}
+
+	content := service.DescribeRoll(roll)
+	items := service.RollItems(roll)
+	if req.CharacterID != "" && len(items) > 0 {
+		char, err := service.GetCharacter(gameID, req.CharacterID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch character").SetInternal(err)
+		}
+		if char == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Character not found")
+		}
+		if err := service.AddInventoryItems(gameID, req.CharacterID, items); err != nil {
+			return randomTableError(err, "Failed to add loot to inventory")
+		}
+		recordAudit(c, gameID, service.AuditRandomTableLoot, "character", req.CharacterID, nil,
+			map[string]any{"table_id": tableID, "items": items})
+		content += fmt.Sprintf(" (added to %s's inventory)", char.Name)
+		broadcastCharacterUpdate(gameID, req.CharacterID)
+	}
+
+	msg := model.ChatMessage{
+		GameID:     gameID,
+		SenderID:   userID,
+		SenderName: "GM",
+		Content:    content,
+		Type:       "EVENT",
+		CreatedAt:  time.Now(),
+	}
+	// Secret rolls work like secret dice rolls: a private message to oneself
+	if req.Secret {
+		msg.Type = "CHAT_PRIVATE"
+		msg.TargetID = &userID
+	}
+	if err := service.SaveMessage(msg); err != nil {
+		fmt.Printf("Error saving random table roll: %v\n", err)
+	}
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, msg)
+	}
+
+	return c.JSON(http.StatusOK, roll)
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastSession notifies the game that a session was scheduled, changed or answered.
+func broadcastSession(gameID string, session *model.GameSession) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+		"type":    "SESSION_UPDATED",
+		"game_id": gameID,
+		"payload": session,
+	})
+}
+
+func GetSessions(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	sessions, err := service.GetGameSessions(gameID, c.QueryParam("include_past") == "true")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func CreateSession(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.SessionInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	session, err := service.CreateSession(gameID, userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSession) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to schedule session").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditSessionCreate, "session", session.ID, nil, session)
+
+	broadcastSession(gameID, session)
+
+	return c.JSON(http.StatusCreated, session)
+}
+
+func UpdateSession(c echo.Context) error {
+	gameID := c.Param("id")
+	sessionID := c.Param("sessionId")
+
+	var req service.SessionInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetSession(gameID, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch session").SetInternal(err)
+	}
+
+	session, err := service.UpdateSession(gameID, sessionID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSession) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update session").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditSessionUpdate, "session", sessionID, before, session)
+
+	broadcastSession(gameID, session)
+
+	return c.JSON(http.StatusOK, session)
+}
+
+func CancelSession(c echo.Context) error {
+	gameID := c.Param("id")
+	sessionID := c.Param("sessionId")
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetSession(gameID, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch session").SetInternal(err)
+	}
+
+	session, err := service.CancelSession(gameID, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to cancel session").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditSessionCancel, "session", sessionID, before, session)
+
+	broadcastSession(gameID, session)
+
+	return c.JSON(http.StatusOK, session)
+}
+
+func RSVPSession(c echo.Context) error {
+	gameID := c.Param("id")
+	sessionID := c.Param("sessionId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req struct {
+		Status string `json:"status"` // yes, no or maybe
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	session, err := service.SetRSVP(gameID, sessionID, userID, req.Status)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRSVP) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save RSVP").SetInternal(err)
+	}
+
+	broadcastSession(gameID, session)
+
+	return c.JSON(http.StatusOK, session)
+}
+
+func calendarResponse(c echo.Context, name string, sessions []model.GameSession) error {
+	c.Response().Header().Set(echo.HeaderContentDisposition, `inline; filename="sessions.ics"`)
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", service.BuildCalendar(name, sessions))
+}
+
+func GetTableCalendar(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	game, _, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := service.GetGameSessions(gameID, true)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions").SetInternal(err)
+	}
+
+	return calendarResponse(c, game.Name, sessions)
+}
+
+func GetUserCalendar(c echo.Context) error {
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	sessions, err := service.GetUserSessions(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions").SetInternal(err)
+	}
+
+	return calendarResponse(c, "QuestHub", sessions)
+}
+
+func UpdateSessionRecap(c echo.Context) error {
+	gameID := c.Param("id")
+	sessionID := c.Param("sessionId")
+
+	var req struct {
+		Recap string `json:"recap"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetSession(gameID, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch session").SetInternal(err)
+	}
+
+	session, err := service.UpdateSessionRecap(gameID, sessionID, req.Recap)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save recap").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditSessionRecap, "session", sessionID, map[string]string{"recap": before.Recap}, map[string]string{"recap": session.Recap})
+
+	broadcastSession(gameID, session)
+
+	return c.JSON(http.StatusOK, session)
+}
+
+// GetSessionTranscript renders the recap and chat log of a session as
+// Markdown (default) or HTML. ?tz= sets the timezone of the timestamps.
+func GetSessionTranscript(c echo.Context) error {
+	gameID := c.Param("id")
+	sessionID := c.Param("sessionId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	loc := time.UTC
+	if tz := c.QueryParam("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid timezone")
+		}
+	}
+
+	session, err := service.GetSession(gameID, sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch session").SetInternal(err)
+	}
+
+	messages, err := service.GetSessionMessages(gameID, sessionID, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch messages").SetInternal(err)
+	}
+
+	body, contentType, err := service.RenderTranscript(session, messages, c.QueryParam("format"), loc)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.Blob(http.StatusOK, contentType, body)
+}
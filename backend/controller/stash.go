@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastStash sends the stash to the game, and the updated character to its owner.
+func broadcastStash(gameID string, stash *model.PartyStash, entry *model.StashLedgerEntry) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+		"type":    "STASH_UPDATED",
+		"game_id": gameID,
+		"payload": map[string]any{"stash": stash, "movement": entry},
+	})
+	if entry != nil && entry.CharacterID != nil {
+		broadcastCharacterUpdate(gameID, *entry.CharacterID)
+	}
+}
+
+func stashError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrEmptyStashMovement), errors.Is(err, service.ErrInsufficientFunds),
+		errors.Is(err, service.ErrInvalidStashItem), errors.Is(err, service.ErrInvalidItems),
+		errors.Is(err, service.ErrInvalidInventory), errors.Is(err, service.ErrUploadNotOwned):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrStashWithdrawLocked):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case err.Error() == "character not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Character not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback).SetInternal(err)
+}
+
+// requireStashCharacter checks that a player moves money or items of their
+// own character. The GM may use any character, or none.
+func requireStashCharacter(gameID, userID string, isGM bool, m service.StashMovement) error {
+	if isGM {
+		return nil
+	}
+	if m.CharacterID == "" || len(m.Items) > 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the GM can add or remove items without a character")
+	}
+	char, err := service.GetCharacter(gameID, m.CharacterID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch character").SetInternal(err)
+	}
+	if char == nil || char.UserID == nil || *char.UserID != userID {
+		return echo.NewHTTPError(http.StatusForbidden, "You can only use your own character")
+	}
+	return nil
+}
+
+func GetStash(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	stash, err := service.GetStash(gameID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch stash").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, stash)
+}
+
+func GetStashLedger(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	limit := 0
+	if raw := c.QueryParam("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+	}
+
+	entries, err := service.GetStashLedger(gameID, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch stash ledger").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+func DepositToStash(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	var req service.StashMovement
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := requireStashCharacter(gameID, userID, isGM, req); err != nil {
+		return err
+	}
+
+	stash, entry, err := service.DepositToStash(gameID, userID, req)
+	if err != nil {
+		return stashError(err, "Failed to deposit to stash")
+	}
+
+	broadcastStash(gameID, stash, entry)
+
+	return c.JSON(http.StatusOK, stash)
+}
+
+// WithdrawFromStash lets the GM take from the stash, and players too when
+// the GM allowed free withdrawals.
+func WithdrawFromStash(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	var req service.StashMovement
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := requireStashCharacter(gameID, userID, isGM, req); err != nil {
+		return err
+	}
+
+	stash, entry, err := service.WithdrawFromStash(gameID, userID, req, !isGM)
+	if err != nil {
+		return stashError(err, "Failed to withdraw from stash")
+	}
+
+	broadcastStash(gameID, stash, entry)
+
+	return c.JSON(http.StatusOK, stash)
+}
+
+func UpdateStashSettings(c echo.Context) error {
+	gameID := c.Param("id")
+
+	var req struct {
+		FreeWithdraw bool `json:"free_withdraw"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	stash, err := service.SetStashFreeWithdraw(gameID, req.FreeWithdraw)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update stash settings").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditStashSettings, "game", gameID, nil, map[string]bool{"free_withdraw": req.FreeWithdraw})
+
+	broadcastStash(gameID, stash, nil)
+
+	return c.JSON(http.StatusOK, stash)
+}
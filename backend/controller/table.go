@@ -2,14 +2,15 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"questhub/imaging"
+	"questhub/middleware"
 	model "questhub/models/database"
 	"questhub/models/request"
 	"questhub/service"
+	"questhub/storage"
 	"questhub/websocket"
 	"strconv"
 	"strings"
@@ -19,6 +20,25 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// checkImages refuses an image value or item image the uploader did not
+// upload, unless kept lists it as already stored on the record.
+func checkImages(imageKey string, items []map[string]interface{}, uploaderID string, kept map[string]bool) error {
+	keys := []string{imageKey}
+	for _, item := range items {
+		key, _ := item["image_url"].(string)
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if err := service.CheckUploadKey(key, uploaderID, kept); err != nil {
+			if errors.Is(err, service.ErrUploadNotOwned) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check images").SetInternal(err)
+		}
+	}
+	return nil
+}
+
 func CreateTable(c echo.Context) error {
 	var req request.CreateTableRequest
 	if err := c.Bind(&req); err != nil {
@@ -32,7 +52,12 @@ func CreateTable(c echo.Context) error {
 	claims := c.Get("claims").(jwt.MapClaims)
 	gmID := claims["sub"].(string)
 
-	game, err := service.CreateTable(req.Name, gmID, req.ImageURL)
+	imageKey := storage.KeyFromURL(req.ImageURL)
+	if err := checkImages(imageKey, nil, gmID, nil); err != nil {
+		return err
+	}
+
+	game, err := service.CreateTable(req.Name, gmID, imageKey)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create table").SetInternal(err)
 	}
@@ -127,6 +152,9 @@ func JoinTable(c echo.Context) error {
 
 	gameID, err := service.JoinTable(req.InviteCode, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrUserBanned) {
+			return echo.NewHTTPError(http.StatusForbidden, "You are banned from this game")
+		}
 		// Check for unique constraint violation (user already in game or invited)
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return c.JSON(http.StatusConflict, map[string]string{
@@ -137,9 +165,50 @@ func JoinTable(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "Game not found or failed to join")
 	}
 
+	// Notify the GM in real time
+	if game, err := service.GetTable(gameID); err == nil {
+		if invitation, err := service.GetInvitation(gameID, userID); err == nil {
+			notifyInvitation(game.GmID, "INVITATION_CREATED", invitation)
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation sent", "id": gameID})
 }
 
+// requireGameMember loads the game and checks the user is its GM or one of its players.
+// Returned errors are HTTP errors.
+func requireGameMember(gameID, userID string) (*model.Game, bool, error) {
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		return nil, false, echo.NewHTTPError(http.StatusNotFound, "Game not found")
+	}
+	if game.GmID == userID {
+		return game, true, nil
+	}
+
+	isMember, err := service.IsGameMember(gameID, userID)
+	if err != nil {
+		return nil, false, echo.NewHTTPError(http.StatusInternalServerError, "Failed to check membership").SetInternal(err)
+	}
+	if !isMember {
+		return nil, false, echo.NewHTTPError(http.StatusForbidden, "You are not a player of this game")
+	}
+	return game, false, nil
+}
+
+// notifyInvitation pushes an invitation lifecycle event to a single user.
+func notifyInvitation(userID, msgType string, payload any) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	msg := map[string]any{
+		"type":    msgType,
+		"payload": payload,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	websocket.GlobalHub.SendToUser(userID, msgBytes)
+}
+
 func GetPendingInvitations(c echo.Context) error {
 	gameID := c.Param("id")
 	if gameID == "" {
@@ -170,6 +239,10 @@ func AcceptInvitation(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to accept invitation").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditInvitationAccept, "player", targetUserID, nil, nil)
+
+	notifyInvitation(targetUserID, "INVITATION_ACCEPTED", map[string]string{"game_id": gameID})
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation accepted"})
 }
 
@@ -187,6 +260,10 @@ func DeclineInvitation(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to decline invitation").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditInvitationDecline, "player", targetUserID, nil, nil)
+
+	notifyInvitation(targetUserID, "INVITATION_DECLINED", map[string]string{"game_id": gameID})
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation declined"})
 }
 
@@ -199,6 +276,9 @@ func DeleteTable(c echo.Context) error {
 	claims := c.Get("claims").(jwt.MapClaims)
 	userID := claims["sub"].(string)
 
+	// Snapshot before deletion for the audit log
+	before, _ := service.GetTable(id)
+
 	if err := service.DeleteTable(id, userID); err != nil {
 		if err.Error() == "unauthorized: only the GM can delete the table" {
 			return echo.NewHTTPError(http.StatusForbidden, err.Error())
@@ -206,6 +286,8 @@ func DeleteTable(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete table").SetInternal(err)
 	}
 
+	recordAudit(c, id, service.AuditTableDelete, "game", id, before, nil)
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Table deleted successfully"})
 }
 
@@ -217,11 +299,20 @@ func RegenerateInviteCode(c echo.Context) error {
 
 	// Verify GM - Handled by middleware
 
+	game, err := service.GetTable(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+	}
+
 	newCode, err := service.RegenerateInviteCode(id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to regenerate invite code").SetInternal(err)
 	}
 
+	recordAudit(c, id, service.AuditInviteCode, "game", id,
+		map[string]string{"invite_code": game.InviteCode},
+		map[string]string{"invite_code": newCode})
+
 	return c.JSON(http.StatusOK, map[string]string{"invite_code": newCode})
 }
 
@@ -269,14 +360,107 @@ func RemovePlayer(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Cannot remove the Game Master")
 	}
 
-	err = service.RemovePlayer(gameID, playerID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove player").SetInternal(err)
+	// Optional ban: DELETE /players/:userId?ban=true&reason=...&expires_at=<RFC3339>
+	if c.QueryParam("ban") == "true" {
+		var expiresAt *time.Time
+		if raw := c.QueryParam("expires_at"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid expires_at, expected RFC3339 date")
+			}
+			expiresAt = &t
+		}
+
+		claims := c.Get("claims").(jwt.MapClaims)
+		gmID := claims["sub"].(string)
+
+		if err := service.BanPlayer(gameID, playerID, gmID, c.QueryParam("reason"), expiresAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to ban player").SetInternal(err)
+		}
+
+		recordAudit(c, gameID, service.AuditPlayerBan, "player", playerID, nil, map[string]any{
+			"reason":     c.QueryParam("reason"),
+			"expires_at": expiresAt,
+		})
+	} else {
+		err = service.RemovePlayer(gameID, playerID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove player").SetInternal(err)
+		}
+
+		recordAudit(c, gameID, service.AuditPlayerRemove, "player", playerID, nil, nil)
+	}
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.KickFromGame(gameID, playerID, "removed")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Player removed successfully"})
 }
 
+func LeaveTable(c echo.Context) error {
+	gameID := c.Param("id")
+	if gameID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing game ID")
+	}
+
+	var req struct {
+		Character string `json:"character"`  // "keep", "unassign" (default) or "archive"
+		KeepNotes bool   `json:"keep_notes"` // Notes are deleted unless asked otherwise
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Character == "" {
+		req.Character = service.LeaveUnassignCharacter
+	}
+	if req.Character != service.LeaveKeepCharacter && req.Character != service.LeaveUnassignCharacter && req.Character != service.LeaveArchiveCharacter {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid character option. Must be 'keep', 'unassign' or 'archive'")
+	}
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+	}
+	if game.GmID == userID {
+		return echo.NewHTTPError(http.StatusBadRequest, "The Game Master cannot leave their own game")
+	}
+
+	if err := service.LeaveTable(gameID, userID, req.Character, req.KeepNotes); err != nil {
+		if err.Error() == "not a member of this game" {
+			return echo.NewHTTPError(http.StatusNotFound, "You are not a member of this game")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to leave game").SetInternal(err)
+	}
+
+	// Announce the departure to the table
+	userName, _ := claims["name"].(string)
+	if userName == "" {
+		userName = "A player"
+	}
+	msg := model.ChatMessage{
+		GameID:     gameID,
+		SenderID:   "System",
+		SenderName: "System",
+		Content:    fmt.Sprintf("%s has left the game.", userName),
+		Type:       "EVENT",
+		CreatedAt:  time.Now(),
+	}
+	if err := service.SaveMessage(msg); err != nil {
+		fmt.Printf("Error saving leave message: %v\n", err)
+	}
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, msg)
+		// Close the game room on the player's other open tabs
+		websocket.GlobalHub.KickFromGame(gameID, userID, "left")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "You left the game"})
+}
+
 func GetGameCharacters(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
@@ -285,7 +469,9 @@ func GetGameCharacters(c echo.Context) error {
 
 	// Verify GM - Handled by middleware
 
-	characters, err := service.GetGameCharacters(id)
+	includeArchived := c.QueryParam("include_archived") == "true"
+
+	characters, err := service.GetGameCharacters(id, includeArchived)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch characters").SetInternal(err)
 	}
@@ -388,45 +574,19 @@ func CreateCharacter(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Max HP")
 	}
 
+	// Uploads count against the quota of whoever sends them
+	uploaderID := c.Get("claims").(jwt.MapClaims)["sub"].(string)
+
 	// Handle avatar upload
-	avatarURL := c.FormValue("avatar_url")
+	// Clients send back the public URL of an existing upload, store its key
+	avatarURL := storage.KeyFromURL(c.FormValue("avatar_url"))
 	file, err := c.FormFile("avatar")
 	if err == nil {
-		// Upload file
-		src, err := file.Open()
-		if err != nil {
-			return err
-		}
-		defer func() {
-			_ = src.Close()
-		}()
-
-		// Create uploads directory if not exists
-		if err := os.MkdirAll("uploads", 0755); err != nil {
-			return err
-		}
-
-		// Generate unique filename
-		ext := filepath.Ext(file.Filename)
-		filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-		dstPath := filepath.Join("uploads", filename)
-
-		dst, err := os.Create(dstPath)
+		image, err := saveImage(file, imaging.KindAvatar, uploaderID)
 		if err != nil {
 			return err
 		}
-		defer func() {
-			_ = dst.Close()
-		}()
-
-		if _, err = io.Copy(dst, src); err != nil {
-			return err
-		}
-
-		// Construct full URL
-		scheme := c.Scheme()
-		host := c.Request().Host
-		avatarURL = fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
+		avatarURL = image.Key
 	}
 
 	// Handle inventory images
@@ -437,37 +597,22 @@ func CreateCharacter(c echo.Context) error {
 		} else {
 			updatedInventory := false
 			for i, item := range inventoryItems {
+				if imageURL, ok := item["image_url"].(string); ok {
+					if key := storage.KeyFromURL(imageURL); key != imageURL {
+						item["image_url"] = key
+						updatedInventory = true
+					}
+				}
+
 				formKey := fmt.Sprintf("inventory_image_%d", i)
 				invFile, err := c.FormFile(formKey)
 				if err == nil {
-					src, err := invFile.Open()
+					image, err := saveImage(invFile, imaging.KindImage, uploaderID)
 					if err != nil {
+						fmt.Printf("Error saving inventory image %d: %v\n", i, err)
 						continue
 					}
-					defer func() {
-						_ = src.Close()
-					}()
-
-					ext := filepath.Ext(invFile.Filename)
-					filename := fmt.Sprintf("inv_%d_%d%s", i, time.Now().UnixNano(), ext)
-					dstPath := filepath.Join("uploads", filename)
-
-					dst, err := os.Create(dstPath)
-					if err != nil {
-						continue
-					}
-					defer func() {
-						_ = dst.Close()
-					}()
-
-					if _, err = io.Copy(dst, src); err != nil {
-						continue
-					}
-
-					scheme := c.Scheme()
-					host := c.Request().Host
-					itemURL := fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
-					item["image_url"] = itemURL
+					item["image_url"] = image.Key
 					updatedInventory = true
 				}
 			}
@@ -477,11 +622,17 @@ func CreateCharacter(c echo.Context) error {
 		}
 	}
 
+	if err := checkImages(avatarURL, inventoryItems, uploaderID, nil); err != nil {
+		return err
+	}
+
 	char, err := service.CreateCharacter(gameID, "", name, race, maxHP, isNPC, avatarURL, stats, inventory, money, initiative, age, height, weight, maxSpells, spells, abilities, experience, charType, subRace, armorClass, speed)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create character").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditCharacterCreate, "character", char.ID, nil, char)
+
 	return c.JSON(http.StatusCreated, char)
 }
 
@@ -528,45 +679,19 @@ func UpdateCharacter(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Max HP")
 	}
 
+	// Uploads count against the quota of whoever sends them
+	uploaderID := c.Get("claims").(jwt.MapClaims)["sub"].(string)
+
 	// Handle avatar upload
-	avatarURL := c.FormValue("avatar_url")
+	// Clients send back the public URL of an existing upload, store its key
+	avatarURL := storage.KeyFromURL(c.FormValue("avatar_url"))
 	file, err := c.FormFile("avatar")
 	if err == nil {
-		// Upload file
-		src, err := file.Open()
+		image, err := saveImage(file, imaging.KindAvatar, uploaderID)
 		if err != nil {
 			return err
 		}
-		defer func() {
-			_ = src.Close()
-		}()
-
-		// Create uploads directory if not exists
-		if err := os.MkdirAll("uploads", 0755); err != nil {
-			return err
-		}
-
-		// Generate unique filename
-		ext := filepath.Ext(file.Filename)
-		filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-		dstPath := filepath.Join("uploads", filename)
-
-		dst, err := os.Create(dstPath)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			_ = dst.Close()
-		}()
-
-		if _, err = io.Copy(dst, src); err != nil {
-			return err
-		}
-
-		// Construct full URL
-		scheme := c.Scheme()
-		host := c.Request().Host
-		avatarURL = fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
+		avatarURL = image.Key
 	}
 
 	// Handle inventory images
@@ -577,37 +702,22 @@ func UpdateCharacter(c echo.Context) error {
 		} else {
 			updatedInventory := false
 			for i, item := range inventoryItems {
+				if imageURL, ok := item["image_url"].(string); ok {
+					if key := storage.KeyFromURL(imageURL); key != imageURL {
+						item["image_url"] = key
+						updatedInventory = true
+					}
+				}
+
 				formKey := fmt.Sprintf("inventory_image_%d", i)
 				invFile, err := c.FormFile(formKey)
 				if err == nil {
-					src, err := invFile.Open()
+					image, err := saveImage(invFile, imaging.KindImage, uploaderID)
 					if err != nil {
+						fmt.Printf("Error saving inventory image %d: %v\n", i, err)
 						continue
 					}
-					defer func() {
-						_ = src.Close()
-					}()
-
-					ext := filepath.Ext(invFile.Filename)
-					filename := fmt.Sprintf("inv_%d_%d%s", i, time.Now().UnixNano(), ext)
-					dstPath := filepath.Join("uploads", filename)
-
-					dst, err := os.Create(dstPath)
-					if err != nil {
-						continue
-					}
-					defer func() {
-						_ = dst.Close()
-					}()
-
-					if _, err = io.Copy(dst, src); err != nil {
-						continue
-					}
-
-					scheme := c.Scheme()
-					host := c.Request().Host
-					itemURL := fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
-					item["image_url"] = itemURL
+					item["image_url"] = image.Key
 					updatedInventory = true
 				}
 			}
@@ -617,11 +727,32 @@ func UpdateCharacter(c echo.Context) error {
 		}
 	}
 
+	before, _ := service.GetCharacter(gameID, charID)
+
+	// The character's current images stay allowed, whoever uploaded them
+	var kept map[string]bool
+	if before != nil {
+		avatar := ""
+		if before.AvatarURL != nil {
+			avatar = *before.AvatarURL
+		}
+		kept = service.KeptUploads([]string{avatar}, before.Inventory)
+	}
+	if err := checkImages(avatarURL, inventoryItems, uploaderID, kept); err != nil {
+		return err
+	}
+
 	char, err := service.UpdateCharacter(charID, gameID, name, race, maxHP, isNPC, avatarURL, stats, inventory, money, initiative, age, height, weight, maxSpells, spells, abilities, experience, charType, subRace, armorClass, speed)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update character").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditCharacterUpdate, "character", charID, before, char)
+
+	if before != nil {
+		announceLevelUp(gameID, char.ID, char.Name, before.Level, char.Level)
+	}
+
 	// Broadcast update to the character owner
 	if char.UserID != nil && websocket.GlobalHub != nil {
 		msg := map[string]any{
@@ -629,7 +760,7 @@ func UpdateCharacter(c echo.Context) error {
 			"payload": char,
 		}
 		msgBytes, _ := json.Marshal(msg)
-		websocket.GlobalHub.BroadcastToUser(*char.UserID, msgBytes)
+		websocket.GlobalHub.SendToUser(*char.UserID, msgBytes)
 	}
 
 	return c.JSON(http.StatusOK, char)
@@ -655,10 +786,14 @@ func DeleteCharacter(c echo.Context) error {
 
 	// Verify GM - Handled by middleware
 
+	before, _ := service.GetCharacter(gameID, charID)
+
 	if err := service.DeleteCharacter(charID, gameID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete character").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditCharacterDelete, "character", charID, before, nil)
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Character deleted successfully"})
 }
 
@@ -678,10 +813,19 @@ func AssignCharacter(c echo.Context) error {
 
 	// Verify GM - Handled by middleware
 
+	var previousOwner *string
+	if before, err := service.GetCharacter(gameID, charID); err == nil && before != nil {
+		previousOwner = before.UserID
+	}
+
 	if err := service.AssignCharacterToPlayer(gameID, charID, req.PlayerID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to assign character").SetInternal(err)
 	}
 
+	recordAudit(c, gameID, service.AuditCharacterAssign, "character", charID,
+		map[string]any{"user_id": previousOwner},
+		map[string]any{"user_id": req.PlayerID})
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Character assigned successfully"})
 }
 
@@ -728,9 +872,10 @@ func GetCharacterNotes(c echo.Context) error {
 		}
 	}
 
-	notes, err := service.GetNotes(gameID, targetUserID)
+	// Only the first note of the player, see GetNotes for the others
+	notes, err := service.GetNotes(gameID, targetUserID, targetUserID == requestingUserID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notes").SetInternal(err)
+		return noteError(err, "Failed to fetch notes")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"content": notes})
@@ -785,10 +930,14 @@ func UpdateCharacterNotes(c echo.Context) error {
 		}
 	}
 
-	if err := service.UpdateNotes(gameID, targetUserID, req.Content); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update notes").SetInternal(err)
+	note, err := service.UpdateNotes(gameID, targetUserID, req.Content, targetUserID == requestingUserID)
+	if err != nil {
+		return noteError(err, "Failed to update notes")
 	}
 
+	// Only the content changed, so nobody loses access
+	broadcastNote(gameID, note, nil)
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Notes updated successfully"})
 }
 
@@ -821,6 +970,12 @@ func SendMessage(c echo.Context) error {
 		CreatedAt:  time.Now(),
 	}
 
+	// Bots always speak under their token name
+	if middleware.IsAPIToken(claims) {
+		msg.IsBot = true
+		msg.SenderName = middleware.APITokenName(claims)
+	}
+
 	if err := service.SaveMessage(msg); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save message").SetInternal(err)
 	}
@@ -853,10 +1008,34 @@ func UpdateTableState(c echo.Context) error {
 
 	// Verify GM - Handled by middleware
 
+	game, err := service.GetTable(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+	}
+
 	if err := service.UpdateGameState(id, req.State); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update game state").SetInternal(err)
 	}
 
+	recordAudit(c, id, service.AuditTableState, "game", id,
+		map[string]string{"state": game.State},
+		map[string]string{"state": req.State})
+
+	// Going ongoing opens the session log, pausing closes it
+	if game.State != req.State {
+		var session *model.GameSession
+		if req.State == "ongoing" {
+			session, err = service.StartSession(id, game.GmID)
+		} else {
+			session, err = service.EndSession(id)
+		}
+		if err != nil {
+			fmt.Printf("Error updating session log: %v\n", err)
+		} else if session != nil {
+			broadcastSession(id, session)
+		}
+	}
+
 	// Optional: Broadcast state change via WS?
 	if websocket.GlobalHub != nil {
 		msg := map[string]string{
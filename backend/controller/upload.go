@@ -1,72 +1,84 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
+	"questhub/imaging"
+	"questhub/service"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
-func UploadImage(c echo.Context) error {
-	// Source
-	file, err := c.FormFile("image")
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No file uploaded"})
-	}
-
-	// Validate file type
-	contentType := file.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "File is not an image"})
+// saveImage validates, re-encodes and stores an uploaded image with the
+// thumbnails for its kind. Returned errors are HTTP errors.
+func saveImage(file *multipart.FileHeader, kind imaging.Kind, ownerID string) (*service.StoredImage, error) {
+	if file.Size > imaging.MaxUploadSize {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
 	}
 
 	src, err := file.Open()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		_ = src.Close()
 	}()
 
-	// Destination
-	// Create uploads directory if it doesn't exist
-	if _, err := os.Stat("uploads"); os.IsNotExist(err) {
-		if err := os.Mkdir("uploads", 0755); err != nil {
-			return err
+	// Read one extra byte so oversized bodies are detected even if Size lied
+	data, err := io.ReadAll(io.LimitReader(src, imaging.MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := service.SaveImage(data, kind, ownerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrQuotaExceeded):
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Storage quota exceeded")
+		case errors.Is(err, imaging.ErrTooLarge):
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
+		case errors.Is(err, imaging.ErrNotImage), errors.Is(err, imaging.ErrTooManyPixels):
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to store image").SetInternal(err)
 		}
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	dstPath := filepath.Join("uploads", filename)
+	return image, nil
+}
 
-	dst, err := os.Create(dstPath)
+func UploadImage(c echo.Context) error {
+	// Source
+	file, err := c.FormFile("image")
 	if err != nil {
-		return err
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No file uploaded"})
 	}
-	defer func() {
-		_ = dst.Close()
-	}()
 
-	// Copy
-	if _, err = io.Copy(dst, src); err != nil {
+	// "avatar" and "cover" get dedicated thumbnail sizes
+	kind := imaging.ParseKind(c.FormValue("kind"))
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	image, err := saveImage(file, kind, userID)
+	if err != nil {
 		return err
 	}
 
-	// Return public URL
-	// Assuming the server is running on the same host and port as configured or default
-	// In production, you might want to use a configured base URL
-	scheme := c.Scheme()
-	host := c.Request().Host
-	publicURL := fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
+	return c.JSON(http.StatusOK, image)
+}
+
+func GetUserStorage(c echo.Context) error {
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	usage, err := service.GetStorageUsage(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch storage usage").SetInternal(err)
+	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"url": publicURL,
-	})
+	return c.JSON(http.StatusOK, usage)
 }
@@ -31,3 +31,44 @@ func GetUserCampaigns(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, campaigns)
 }
+
+func GetUserInvitations(c echo.Context) error {
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	invitations, err := service.GetUserInvitations(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch invitations").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, invitations)
+}
+
+func CancelInvitation(c echo.Context) error {
+	invitationID := c.Param("invitationId")
+	if invitationID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing invitation ID")
+	}
+
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	gameID, err := service.CancelInvitation(invitationID, userID)
+	if err != nil {
+		if err.Error() == "invitation not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to cancel invitation").SetInternal(err)
+	}
+
+	// Let the GM drop the request from their pending list
+	if game, err := service.GetTable(gameID); err == nil {
+		notifyInvitation(game.GmID, "INVITATION_CANCELLED", map[string]string{
+			"id":      invitationID,
+			"game_id": gameID,
+			"user_id": userID,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation cancelled"})
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// broadcastWikiPage tells the game a page changed. The content is left out
+// so players fetch the version they are allowed to read.
+func broadcastWikiPage(gameID, previousSlug string, page *model.WikiPage) {
+	if websocket.GlobalHub == nil {
+		return
+	}
+	websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+		"type":    "WIKI_UPDATED",
+		"game_id": gameID,
+		"payload": map[string]any{
+			"previous_slug": previousSlug,
+			"slug":          page.Slug,
+			"title":         page.Title,
+			"category":      page.Category,
+			"updated_at":    page.UpdatedAt,
+		},
+	})
+}
+
+func wikiError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidWikiPage), errors.Is(err, service.ErrWikiTitleReserved):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrWikiPageExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case err.Error() == "wiki page not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	case err.Error() == "revision not found":
+		return echo.NewHTTPError(http.StatusNotFound, "Revision not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback).SetInternal(err)
+}
+
+func GetWikiPages(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	pages, err := service.GetWikiPages(gameID, c.QueryParam("category"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch wiki pages").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, pages)
+}
+
+// SearchWiki searches titles and content. Players do not match the GM secret sections.
+func SearchWiki(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Search query is required")
+	}
+
+	results, err := service.SearchWiki(gameID, query, isGM)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search wiki").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+func GetWikiPage(c echo.Context) error {
+	gameID := c.Param("id")
+	slug := c.Param("slug")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	_, isGM, err := requireGameMember(gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	page, err := service.GetWikiPage(gameID, slug, isGM)
+	if err != nil {
+		return wikiError(err, "Failed to fetch wiki page")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func CreateWikiPage(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.WikiPageInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	page, err := service.CreateWikiPage(gameID, userID, req)
+	if err != nil {
+		return wikiError(err, "Failed to create wiki page")
+	}
+
+	recordAudit(c, gameID, service.AuditWikiCreate, "wiki_page", page.ID, nil, page)
+
+	broadcastWikiPage(gameID, "", page)
+
+	return c.JSON(http.StatusCreated, page)
+}
+
+func UpdateWikiPage(c echo.Context) error {
+	gameID := c.Param("id")
+	slug := c.Param("slug")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	var req service.WikiPageInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, err := service.GetWikiPage(gameID, slug, true)
+	if err != nil {
+		return wikiError(err, "Failed to fetch wiki page")
+	}
+
+	page, err := service.UpdateWikiPage(gameID, slug, userID, req)
+	if err != nil {
+		return wikiError(err, "Failed to update wiki page")
+	}
+
+	recordAudit(c, gameID, service.AuditWikiUpdate, "wiki_page", page.ID, before, page)
+
+	broadcastWikiPage(gameID, slug, page)
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func DeleteWikiPage(c echo.Context) error {
+	gameID := c.Param("id")
+	slug := c.Param("slug")
+
+	// Verify GM - Handled by middleware
+	page, err := service.GetWikiPage(gameID, slug, true)
+	if err != nil {
+		return wikiError(err, "Failed to fetch wiki page")
+	}
+	if err := service.DeleteWikiPage(gameID, slug); err != nil {
+		return wikiError(err, "Failed to delete wiki page")
+	}
+
+	recordAudit(c, gameID, service.AuditWikiDelete, "wiki_page", page.ID, page, nil)
+
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+			"type":    "WIKI_DELETED",
+			"game_id": gameID,
+			"payload": map[string]string{"slug": slug},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Page deleted"})
+}
+
+func GetWikiRevisions(c echo.Context) error {
+	gameID := c.Param("id")
+	slug := c.Param("slug")
+
+	// Verify GM - Handled by middleware
+	revisions, err := service.GetWikiRevisions(gameID, slug)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch revisions").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// RestoreWikiRevision saves an old revision as the current version of the page.
+func RestoreWikiRevision(c echo.Context) error {
+	gameID := c.Param("id")
+	slug := c.Param("slug")
+	revisionID := c.Param("revisionId")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	// Verify GM - Handled by middleware
+	page, err := service.RestoreWikiRevision(gameID, slug, revisionID, userID)
+	if err != nil {
+		return wikiError(err, "Failed to restore revision")
+	}
+
+	recordAudit(c, gameID, service.AuditWikiRestore, "wiki_page", page.ID, nil, map[string]string{"revision_id": revisionID})
+
+	broadcastWikiPage(gameID, slug, page)
+
+	return c.JSON(http.StatusOK, page)
+}
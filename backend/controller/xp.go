@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	model "questhub/models/database"
+	"questhub/service"
+	"questhub/websocket"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// postSystemEvent saves an EVENT message from the system and sends it to the game.
+func postSystemEvent(gameID, content string) {
+	msg := model.ChatMessage{
+		GameID:     gameID,
+		SenderID:   "System",
+		SenderName: "System",
+		Content:    content,
+		Type:       "EVENT",
+		CreatedAt:  time.Now(),
+	}
+	if err := service.SaveMessage(msg); err != nil {
+		fmt.Printf("Error saving system message: %v\n", err)
+	}
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, msg)
+	}
+}
+
+// announceLevelUp posts the level up in chat and sends LEVEL_UP to the game.
+func announceLevelUp(gameID, charID, name string, previousLevel, level int) {
+	if level <= previousLevel {
+		return
+	}
+	postSystemEvent(gameID, fmt.Sprintf("⬆️ %s reached level %d!", name, level))
+	if websocket.GlobalHub != nil {
+		websocket.GlobalHub.BroadcastToGame(gameID, map[string]any{
+			"type":    "LEVEL_UP",
+			"game_id": gameID,
+			"payload": map[string]any{
+				"character_id":   charID,
+				"name":           name,
+				"previous_level": previousLevel,
+				"level":          level,
+			},
+		})
+	}
+}
+
+// AwardXP gives experience to selected characters, or to every player
+// character, each getting the amount or a share of it.
+func AwardXP(c echo.Context) error {
+	gameID := c.Param("id")
+
+	var req struct {
+		Amount       int      `json:"amount"`
+		CharacterIDs []string `json:"character_ids"` // Empty for every player character
+		Split        bool     `json:"split"`
+		Reason       string   `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	awards, err := service.AwardXP(gameID, req.CharacterIDs, req.Amount, req.Split)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidXP), errors.Is(err, service.ErrNoCharacters), errors.Is(err, service.ErrUnknownCharacter):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case err.Error() == "game not found":
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to award XP").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditXPAward, "game", gameID, nil, awards)
+
+	names := make([]string, 0, len(awards))
+	for _, a := range awards {
+		names = append(names, a.Name)
+	}
+	content := fmt.Sprintf("✨ %d XP each for %s", awards[0].Amount, strings.Join(names, ", "))
+	if req.Reason != "" {
+		content += ": " + req.Reason
+	}
+	postSystemEvent(gameID, content)
+
+	for _, a := range awards {
+		broadcastCharacterUpdate(gameID, a.CharacterID)
+		announceLevelUp(gameID, a.CharacterID, a.Name, a.PreviousLevel, a.Level)
+	}
+
+	return c.JSON(http.StatusOK, awards)
+}
+
+func GetLevelThresholds(c echo.Context) error {
+	gameID := c.Param("id")
+	claims := c.Get("claims").(jwt.MapClaims)
+	userID := claims["sub"].(string)
+
+	if _, _, err := requireGameMember(gameID, userID); err != nil {
+		return err
+	}
+
+	thresholds, err := service.GetLevelThresholds(gameID)
+	if err != nil {
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch level thresholds").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string][]int{"thresholds": thresholds})
+}
+
+func UpdateLevelThresholds(c echo.Context) error {
+	gameID := c.Param("id")
+
+	var req struct {
+		Thresholds []int `json:"thresholds"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// Verify GM - Handled by middleware
+	before, _ := service.GetLevelThresholds(gameID)
+
+	if err := service.SetLevelThresholds(gameID, req.Thresholds); err != nil {
+		if errors.Is(err, service.ErrInvalidThresholds) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err.Error() == "game not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "Game not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update level thresholds").SetInternal(err)
+	}
+
+	recordAudit(c, gameID, service.AuditLevelThresholds, "game", gameID,
+		map[string][]int{"thresholds": before},
+		map[string][]int{"thresholds": req.Thresholds})
+
+	return c.JSON(http.StatusOK, map[string][]int{"thresholds": req.Thresholds})
+}
@@ -0,0 +1,53 @@
+package devauth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterRoutes serves the JWKS at the same path as better-auth and a token
+// endpoint for the seeded users. Only mounted when dev auth is enabled.
+func (p *Provider) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/auth/jwks", p.serveJWKS)
+
+	g := e.Group("/dev/auth")
+	g.GET("/users", listUsers)
+	g.POST("/token", p.issueToken)
+}
+
+func (p *Provider) serveJWKS(c echo.Context) error {
+	jwks, err := p.JWKS()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build JWKS").SetInternal(err)
+	}
+	return c.JSONBlob(http.StatusOK, jwks)
+}
+
+func listUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, SeedUsers)
+}
+
+func (p *Provider) issueToken(c echo.Context) error {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	user, ok := FindSeedUser(req.UserID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown dev user")
+	}
+
+	token, err := p.Mint(user, DefaultTokenTTL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign token").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"token": token,
+		"user":  user,
+	})
+}
@@ -0,0 +1,134 @@
+// Package devauth is a built-in identity provider for local development and
+// tests. It signs JWTs the same way better-auth does (EdDSA) and serves its
+// own JWKS, so the backend can run without the frontend auth service.
+package devauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultTokenTTL matches the lifetime of better-auth JWTs.
+const DefaultTokenTTL = 15 * time.Minute
+
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// SeedUsers are created in the database when the provider is enabled.
+var SeedUsers = []User{
+	{ID: "dev-gm", Name: "Dev GM", Email: "gm@questhub.local"},
+	{ID: "dev-player-1", Name: "Dev Player 1", Email: "player1@questhub.local"},
+	{ID: "dev-player-2", Name: "Dev Player 2", Email: "player2@questhub.local"},
+}
+
+type Provider struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	kid        string
+	issuer     string
+}
+
+// NewProvider creates a provider with a fresh signing key.
+func NewProvider(issuer string) (*Provider, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return newProviderFromSeed(seed, issuer), nil
+}
+
+// LoadProvider reuses the signing key stored in keyFile, creating it if
+// needed, so tokens survive backend restarts (e.g. with air live reload).
+func LoadProvider(keyFile, issuer string) (*Provider, error) {
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid dev auth key file %s", keyFile)
+		}
+		return newProviderFromSeed(seed, issuer), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	provider, err := NewProvider(issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(provider.privateKey.Seed())), 0600); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func newProviderFromSeed(seed []byte, issuer string) *Provider {
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(publicKey)
+
+	return &Provider{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		kid:        hex.EncodeToString(sum[:8]),
+		issuer:     issuer,
+	}
+}
+
+// JWKS returns the public key set in the format served by better-auth.
+func (p *Provider) JWKS() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"alg": "EdDSA",
+				"use": "sig",
+				"kid": p.kid,
+				"x":   base64.RawURLEncoding.EncodeToString(p.publicKey),
+			},
+		},
+	})
+}
+
+// Mint signs a token for any user, seeded or not.
+func (p *Provider) Mint(user User, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"name":  user.Name,
+		"email": user.Email,
+		"iss":   p.issuer,
+		"aud":   p.issuer,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = p.kid
+	return token.SignedString(p.privateKey)
+}
+
+// FindSeedUser looks up a seeded user by ID.
+func FindSeedUser(id string) (User, bool) {
+	for _, user := range SeedUsers {
+		if user.ID == id {
+			return user, true
+		}
+	}
+	return User{}, false
+}
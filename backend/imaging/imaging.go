@@ -0,0 +1,259 @@
+// Package imaging validates uploaded images and re-encodes them, together
+// with their thumbnails, into a safe format without metadata.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register decoders accepted on upload
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxUploadSize is the largest accepted upload, in bytes.
+const MaxUploadSize = 10 << 20
+
+const (
+	// Decoded size limit, protects against decompression bombs
+	maxPixels = 40_000_000
+	// The stored "original" is downscaled to fit in this box
+	maxOriginalDimension = 2048
+	jpegQuality          = 85
+)
+
+var (
+	ErrNotImage      = errors.New("file is not a supported image")
+	ErrTooLarge      = errors.New("file is too large")
+	ErrTooManyPixels = errors.New("image dimensions are too large")
+)
+
+// Kind selects the thumbnail sizes generated for an upload.
+type Kind string
+
+const (
+	KindImage  Kind = "image"
+	KindAvatar Kind = "avatar"
+	KindCover  Kind = "cover"
+)
+
+type size struct {
+	name   string
+	width  int
+	height int
+	crop   bool // Fill the box and crop the overflow, otherwise fit inside it
+}
+
+var sizes = map[Kind][]size{
+	KindImage: {
+		{name: "thumb", width: 256, height: 256},
+	},
+	KindAvatar: {
+		{name: "small", width: 64, height: 64, crop: true},
+		{name: "medium", width: 128, height: 128, crop: true},
+		{name: "large", width: 256, height: 256, crop: true},
+	},
+	KindCover: {
+		{name: "small", width: 320, height: 180, crop: true},
+		{name: "medium", width: 640, height: 360, crop: true},
+		{name: "large", width: 1280, height: 720, crop: true},
+	},
+}
+
+// ParseKind returns the matching Kind, defaulting to KindImage.
+func ParseKind(value string) Kind {
+	switch Kind(value) {
+	case KindAvatar, KindCover:
+		return Kind(value)
+	default:
+		return KindImage
+	}
+}
+
+var allowedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// OriginalVariant names the re-encoded full-size image among the variants.
+const OriginalVariant = "original"
+
+// SizeNames lists every thumbnail name across kinds.
+func SizeNames() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, kindSizes := range sizes {
+		for _, s := range kindSizes {
+			if !seen[s.name] {
+				seen[s.name] = true
+				names = append(names, s.name)
+			}
+		}
+	}
+	return names
+}
+
+// KindSizeNames lists the thumbnails generated for a kind.
+func KindSizeNames(kind Kind) []string {
+	names := []string{}
+	for _, s := range sizes[kind] {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Variant is one encoded rendition of the upload.
+type Variant struct {
+	Name        string
+	Data        []byte
+	Ext         string
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Process sniffs and decodes the upload, then returns the re-encoded original
+// followed by the thumbnails for the kind. Re-encoding drops EXIF and any
+// other embedded metadata.
+func Process(data []byte, kind Kind) ([]Variant, error) {
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedContentTypes[contentType] {
+		return nil, ErrNotImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+
+	// Orientation lives in EXIF, which is about to be dropped: apply it now
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	opaque := isOpaque(img)
+
+	original := fit(img, maxOriginalDimension, maxOriginalDimension)
+	variant, err := encode(OriginalVariant, original, opaque)
+	if err != nil {
+		return nil, err
+	}
+	variants := []Variant{variant}
+
+	for _, s := range sizes[kind] {
+		var resized image.Image
+		if s.crop {
+			resized = fill(img, s.width, s.height)
+		} else {
+			resized = fit(img, s.width, s.height)
+		}
+		variant, err := encode(s.name, resized, opaque)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// encode writes JPEG for opaque images and PNG when transparency must be kept.
+func encode(name string, img image.Image, opaque bool) (Variant, error) {
+	var buf bytes.Buffer
+	variant := Variant{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	if opaque {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Variant{}, err
+		}
+		variant.Ext = ".jpg"
+		variant.ContentType = "image/jpeg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return Variant{}, err
+		}
+		variant.Ext = ".png"
+		variant.ContentType = "image/png"
+	}
+
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// fit scales the image down to fit in the box, keeping its ratio. Smaller
+// images are left as is.
+func fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return toRGBA(img)
+	}
+
+	ratio := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	dstWidth := max(1, int(float64(width)*ratio))
+	dstHeight := max(1, int(float64(height)*ratio))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// fill scales the image to cover the box and crops the centre.
+func fill(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	// Largest centred source rectangle with the target ratio
+	cropWidth, cropHeight := srcWidth, srcWidth*height/width
+	if cropHeight > srcHeight {
+		cropWidth, cropHeight = srcHeight*width/height, srcHeight
+	}
+	x0 := bounds.Min.X + (srcWidth-cropWidth)/2
+	y0 := bounds.Min.Y + (srcHeight-cropHeight)/2
+	src := image.Rect(x0, y0, x0+cropWidth, y0+cropHeight)
+
+	// Never upscale small images
+	if cropWidth < width {
+		width, height = cropWidth, cropHeight
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, width), max(1, height)))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
@@ -6,8 +6,11 @@ import (
 
 	"questhub/config"
 	"questhub/database"
+	"questhub/devauth"
 	mdw "questhub/middleware"
 	"questhub/routes"
+	"questhub/service"
+	"questhub/storage"
 
 	"github.com/ZiplEix/better-logs/httpmw"
 
@@ -22,6 +25,16 @@ func main() {
 
 	database.InitDB()
 
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	service.StartUploadGC(config.UploadGCInterval, config.UploadGCGrace)
+
 	// logger, cleanup := config.InitLogger()
 	// defer func() {
 	// 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -33,16 +46,28 @@ func main() {
 	// // Prevent unused variable error if logger is not used directly in main
 	// _ = logger
 
-	authURL := os.Getenv("BETTER_AUTH_URL")
-	if err := mdw.InitJWKS(authURL); err != nil {
-		log.Fatalf("Failed to initialize JWKS: %v", err)
+	var devAuth *devauth.Provider
+	if config.DevAuthEnabled() {
+		devAuth = initDevAuth()
+	} else {
+		authURL := os.Getenv("BETTER_AUTH_URL")
+		if err := mdw.InitJWKS(authURL); err != nil {
+			log.Fatalf("Failed to initialize JWKS: %v", err)
+		}
 	}
 
 	e := echo.New()
 
+	// Calendar feed tokens must not reach the access logs
+	e.Pre(mdw.StripCalendarToken)
 	e.Use(middleware.Recover())
 	e.Use(middleware.RemoveTrailingSlash())
-	e.Use(middleware.CORS())
+	// Checked by IsOriginAllowed, as echo allows every origin when the list is empty
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return config.IsOriginAllowed(origin), nil
+		},
+	}))
 	e.Use(middleware.Logger())
 
 	// Add Better Logs middleware with skipper for WebSocket
@@ -62,16 +87,26 @@ func main() {
 	websocket.GlobalHub = hub
 	go hub.Run()
 
-	// WebSocket Route (Protected)
+	service.StartConditionExpiry(config.ConditionExpiryInterval, hub.BroadcastExpiredConditions)
+
+	// WebSocket Routes (Protected)
+	// The socket itself is opened with a one-time ticket so the JWT never appears in the URL
+	mdw.AllowAPIToken(e.POST("/ws/ticket", websocket.IssueTicket, mdw.JWTMiddleware), service.ScopeReadGame)
 	e.GET("/ws", func(c echo.Context) error {
 		return websocket.ServeWs(hub, c)
-	}, mdw.JWTMiddleware)
+	}, mdw.WsTicketMiddleware)
 
-	// Serve static files
-	e.Static("/uploads", "uploads")
+	// Serve static files, S3 objects are fetched from the bucket directly
+	if storage.IsLocal() {
+		e.Static("/uploads", storage.LocalDir())
+	}
 
 	routes.SetupRoutes(e)
 
+	if devAuth != nil {
+		devAuth.RegisterRoutes(e)
+	}
+
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(200, map[string]string{"status": "ok"})
 	})
@@ -80,3 +115,35 @@ func main() {
 	e.Logger.Fatal(e.Start(":8080"))
 
 }
+
+// initDevAuth replaces better-auth with the built-in provider and seeds its users.
+func initDevAuth() *devauth.Provider {
+	log.Println("WARNING: DEV_AUTH is enabled, tokens are issued by the built-in dev provider")
+
+	var provider *devauth.Provider
+	var err error
+	if keyFile := os.Getenv("DEV_AUTH_KEY_FILE"); keyFile != "" {
+		provider, err = devauth.LoadProvider(keyFile, "questhub-dev")
+	} else {
+		provider, err = devauth.NewProvider("questhub-dev")
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize dev auth provider: %v", err)
+	}
+
+	jwks, err := provider.JWKS()
+	if err != nil {
+		log.Fatalf("Failed to build dev JWKS: %v", err)
+	}
+	if err := mdw.InitJWKSFromJSON(jwks); err != nil {
+		log.Fatalf("Failed to initialize JWKS: %v", err)
+	}
+
+	for _, user := range devauth.SeedUsers {
+		if err := service.EnsureUser(user.ID, user.Name, user.Email); err != nil {
+			log.Printf("Failed to seed dev user %s: %v", user.ID, err)
+		}
+	}
+
+	return provider
+}
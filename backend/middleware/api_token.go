@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"questhub/service"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+const authTypeAPIToken = "api_token"
+
+// Routes reachable with a personal access token, keyed by "METHOD path",
+// with the scope they require. Every other route rejects API tokens.
+var apiTokenRoutes = map[string]string{}
+
+// AllowAPIToken opens a route to personal access tokens holding the scope.
+func AllowAPIToken(route *echo.Route, scope string) *echo.Route {
+	apiTokenRoutes[route.Method+" "+route.Path] = scope
+	return route
+}
+
+func authenticateAPIToken(c echo.Context, tokenStr string, next echo.HandlerFunc) error {
+	scope, allowed := apiTokenRoutes[c.Request().Method+" "+c.Path()]
+	if !allowed {
+		return echo.NewHTTPError(http.StatusForbidden, "API tokens cannot access this route")
+	}
+
+	token, userName, err := service.AuthenticateAPIToken(tokenStr)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIToken) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify token").SetInternal(err)
+	}
+
+	scopes := make([]any, len(token.Scopes))
+	for i, s := range token.Scopes {
+		scopes[i] = s
+	}
+
+	// Same shape as the better-auth claims so handlers keep reading "sub" and "name"
+	claims := jwt.MapClaims{
+		"sub":        token.UserID,
+		"name":       userName,
+		"auth_type":  authTypeAPIToken,
+		"token_id":   token.ID,
+		"token_name": token.Name,
+		"scopes":     scopes,
+	}
+
+	if !HasScope(claims, scope) {
+		return echo.NewHTTPError(http.StatusForbidden, "API token is missing the "+scope+" scope")
+	}
+
+	c.Set("claims", claims)
+
+	return next(c)
+}
+
+// IsAPIToken reports whether the claims come from a personal access token.
+func IsAPIToken(claims jwt.MapClaims) bool {
+	authType, _ := claims["auth_type"].(string)
+	return authType == authTypeAPIToken
+}
+
+// APITokenName returns the token's display name, used as the bot sender name.
+func APITokenName(claims jwt.MapClaims) string {
+	name, _ := claims["token_name"].(string)
+	return name
+}
+
+// HasScope reports whether the claims grant the scope. Regular user sessions
+// have every scope.
+func HasScope(claims jwt.MapClaims, scope string) bool {
+	if !IsAPIToken(claims) {
+		return true
+	}
+	scopes, _ := claims["scopes"].([]any)
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
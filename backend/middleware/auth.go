@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"questhub/service"
 	"strings"
 	"time"
 
@@ -35,6 +37,17 @@ func InitJWKS(authURL string) error {
 	return fmt.Errorf("failed to initialize JWKS after %d attempts: %w", maxRetries, err)
 }
 
+// InitJWKSFromJSON loads a static key set, used by the built-in dev auth
+// provider and tests instead of fetching it from better-auth.
+func InitJWKSFromJSON(jwksJSON []byte) error {
+	var err error
+	jwks, err = keyfunc.NewJSON(jwksJSON)
+	return err
+}
+
+// JWTMiddleware only accepts the token from the Authorization header. The
+// websocket route uses WsTicketMiddleware instead so no token ends up in URLs.
+// Personal access tokens are accepted on routes opened with AllowAPIToken.
 func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		tokenStr := ""
@@ -47,11 +60,12 @@ func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		if tokenStr == "" {
-			tokenStr = c.QueryParam("token")
+			return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
 		}
 
-		if tokenStr == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
+		// Personal access tokens for bots and scripts
+		if strings.HasPrefix(tokenStr, service.APITokenPrefix) {
+			return authenticateAPIToken(c, tokenStr, next)
 		}
 
 		token, err := jwt.Parse(tokenStr, jwks.Keyfunc)
@@ -69,3 +83,74 @@ func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// WsTicketMiddleware authenticates the websocket upgrade with a one-time
+// ticket obtained from POST /ws/ticket.
+func WsTicketMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ticket := c.QueryParam("ticket")
+		if ticket == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "missing ticket")
+		}
+
+		claims, ok := RedeemWsTicket(ticket)
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired ticket")
+		}
+
+		c.Set("claims", claims)
+
+		return next(c)
+	}
+}
+
+// calendarTokenKey holds the feed token taken out of the URL by StripCalendarToken.
+type calendarTokenKey struct{}
+
+// StripCalendarToken moves ?token= out of the URL of .ics feeds before any
+// logger sees it. Registered with e.Pre, so it runs before routing and logging.
+func StripCalendarToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if !strings.HasSuffix(req.URL.Path, ".ics") {
+			return next(c)
+		}
+		query := req.URL.Query()
+		token := query.Get("token")
+		if token == "" {
+			return next(c)
+		}
+
+		query.Del("token")
+		req.URL.RawQuery = query.Encode()
+		req.RequestURI = req.URL.RequestURI()
+		c.SetRequest(req.WithContext(context.WithValue(req.Context(), calendarTokenKey{}, token)))
+		return next(c)
+	}
+}
+
+// CalendarFeedMiddleware authenticates iCalendar feeds. Besides the usual
+// Authorization header it accepts a personal access token in ?token=, as
+// calendar apps subscribe to a plain URL. Such URLs end up in calendar apps
+// and proxies, so only tokens limited to calendar:read are accepted there.
+// JWTs are never accepted in the URL.
+func CalendarFeedMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	jwtNext := JWTMiddleware(next)
+	calendarOnly := func(c echo.Context) error {
+		scopes, _ := c.Get("claims").(jwt.MapClaims)["scopes"].([]any)
+		for _, scope := range scopes {
+			if scope != service.ScopeReadCalendar {
+				return echo.NewHTTPError(http.StatusForbidden, "Feed URLs only accept tokens limited to the "+service.ScopeReadCalendar+" scope")
+			}
+		}
+		return next(c)
+	}
+	return func(c echo.Context) error {
+		if c.Request().Header.Get("Authorization") == "" {
+			if tokenStr, _ := c.Request().Context().Value(calendarTokenKey{}).(string); strings.HasPrefix(tokenStr, service.APITokenPrefix) {
+				return authenticateAPIToken(c, tokenStr, calendarOnly)
+			}
+		}
+		return jwtNext(c)
+	}
+}
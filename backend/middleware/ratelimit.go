@@ -1,50 +1,75 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"questhub/ratelimit"
+
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
-	echoMiddleware "github.com/labstack/echo/v4/middleware"
-	"golang.org/x/time/rate"
 )
 
-// DiceRollRateLimitConfig defines the configuration for dice roll rate limiting
-var DiceRollRateLimitConfig = echoMiddleware.RateLimiterConfig{
-	Skipper: echoMiddleware.DefaultSkipper,
-	Store: echoMiddleware.NewRateLimiterMemoryStoreWithConfig(
-		echoMiddleware.RateLimiterMemoryStoreConfig{
-			Rate:      rate.Every(3 * time.Second),
-			Burst:     1,
-			ExpiresIn: 3 * time.Minute,
-		},
-	),
-	IdentifierExtractor: func(ctx echo.Context) (string, error) {
-		// Identify by User ID if available (JWT), otherwise IP
-		// Dice roll route is protected by JWT, so we should always have claims.
-		// BUT fallback to IP is safe.
-		// However, user provided example used RealIP().
-		// If I use UserID, it's better for authenticated users.
-		// User requirement "3sec par utilisateur".
-		// Let's try to get User ID from context first.
-		claims, ok := ctx.Get("claims").(jwt.MapClaims)
-		if ok {
-			if sub, ok := claims["sub"].(string); ok {
-				return sub, nil
+// RateLimitError is the body returned with 429 responses, shared with the
+// websocket ERROR frames.
+type RateLimitError struct {
+	Error      string `json:"error"`
+	Message    string `json:"message"`
+	Policy     string `json:"policy"`
+	RetryAfter int    `json:"retry_after"` // Seconds
+}
+
+func NewRateLimitError(policy string, retryAfter time.Duration) RateLimitError {
+	seconds := ceilSeconds(retryAfter)
+	return RateLimitError{
+		Error:      "rate_limited",
+		Message:    "Too many requests, retry in " + strconv.Itoa(seconds) + " seconds.",
+		Policy:     policy,
+		RetryAfter: seconds,
+	}
+}
+
+// RateLimit applies the named policy from config.RateLimits to a route.
+// Callers are identified by user ID when authenticated, otherwise by IP.
+func RateLimit(policy string) echo.MiddlewareFunc {
+	store := ratelimit.Get(policy)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			result := store.Allow(rateLimitIdentifier(c))
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return c.JSON(http.StatusTooManyRequests, NewRateLimitError(policy, result.RetryAfter))
 			}
+
+			return next(c)
+		}
+	}
+}
+
+func rateLimitIdentifier(c echo.Context) string {
+	if claims, ok := c.Get("claims").(jwt.MapClaims); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return UserRateLimitKey(sub)
 		}
-		// Fallback to IP
-		return ctx.RealIP(), nil
-	},
-	ErrorHandler: func(context echo.Context, err error) error {
-		return context.JSON(http.StatusTooManyRequests, map[string]string{
-			"error": "Veuillez attendre 3 secondes avant de réessayer.",
-		})
-	},
-	DenyHandler: func(context echo.Context, identifier string, err error) error {
-		return context.JSON(http.StatusTooManyRequests, map[string]string{
-			"error": "Veuillez attendre 3 secondes avant de réessayer.",
-		})
-	},
+	}
+	return "ip:" + c.RealIP()
+}
+
+// UserRateLimitKey identifies a user in a rate-limit store, so websocket
+// frames and HTTP requests count against the same bucket.
+func UserRateLimitKey(userID string) string {
+	return "user:" + userID
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
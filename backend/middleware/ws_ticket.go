@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// WsTicketTTL is how long a websocket ticket stays valid after issuance.
+const WsTicketTTL = 30 * time.Second
+
+type wsTicket struct {
+	claims    jwt.MapClaims
+	expiresAt time.Time
+}
+
+// Websocket tickets are single-use and short-lived, so an in-memory store is
+// enough. They replace the bearer token that used to travel in the /ws URL.
+var (
+	wsTickets   = make(map[string]wsTicket)
+	wsTicketsMu sync.Mutex
+)
+
+// IssueWsTicket creates a one-time ticket bound to the caller's claims.
+func IssueWsTicket(claims jwt.MapClaims) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	ticket := hex.EncodeToString(bytes)
+
+	wsTicketsMu.Lock()
+	defer wsTicketsMu.Unlock()
+
+	// Drop expired tickets that were never redeemed
+	now := time.Now()
+	for key, t := range wsTickets {
+		if now.After(t.expiresAt) {
+			delete(wsTickets, key)
+		}
+	}
+
+	wsTickets[ticket] = wsTicket{claims: claims, expiresAt: now.Add(WsTicketTTL)}
+	return ticket, nil
+}
+
+// RedeemWsTicket consumes a ticket and returns the claims it was issued for.
+func RedeemWsTicket(ticket string) (jwt.MapClaims, bool) {
+	wsTicketsMu.Lock()
+	defer wsTicketsMu.Unlock()
+
+	t, ok := wsTickets[ticket]
+	if !ok {
+		return nil, false
+	}
+	delete(wsTickets, ticket)
+
+	if time.Now().After(t.expiresAt) {
+		return nil, false
+	}
+	return t.claims, true
+}
@@ -0,0 +1,13 @@
+package database
+
+import "time"
+
+type Ban struct {
+	GameID    string     `json:"game_id"`
+	UserID    string     `json:"user_id"`
+	UserName  string     `json:"user_name"`
+	BannedBy  string     `json:"banned_by"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"` // nil means permanent
+	CreatedAt time.Time  `json:"created_at"`
+}
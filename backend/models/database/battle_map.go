@@ -0,0 +1,35 @@
+package database
+
+import "time"
+
+type BattleMap struct {
+	ID            string     `json:"id"`
+	GameID        string     `json:"game_id"`
+	Name          string     `json:"name"`
+	BackgroundURL string     `json:"background_url"`
+	GridSize      int        `json:"grid_size"`
+	Width         int        `json:"width"`
+	Height        int        `json:"height"`
+	IsActive      bool       `json:"is_active"`
+	FogEnabled    bool       `json:"fog_enabled"`
+	RevealedCells []byte     `json:"revealed_cells"` // Bitmap, base64 in JSON
+	Tokens        []MapToken `json:"tokens,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type MapToken struct {
+	ID          string    `json:"id"`
+	MapID       string    `json:"map_id"`
+	CharacterID *string   `json:"character_id"`
+	OwnerID     *string   `json:"owner_id"` // Player of the linked character
+	Label       string    `json:"label"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	X           int       `json:"x"`
+	Y           int       `json:"y"`
+	Size        int       `json:"size"`
+	Color       string    `json:"color"`
+	Hidden      bool      `json:"hidden"`
+	VisibleTo   []string  `json:"visible_to,omitempty"` // GM only
+	UpdatedAt   time.Time `json:"updated_at"`
+}
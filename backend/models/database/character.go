@@ -30,14 +30,11 @@ type Character struct {
 	Spells     json.RawMessage `json:"spells"`
 	Abilities  string          `json:"abilities"`
 	Experience int             `json:"experience"`
+	Level      int             `json:"level"`    // Computed from experience and the game's level thresholds
 	Type       string          `json:"type"`     // PLAYER, NPC, MONSTER
 	SubRace    *string         `json:"sub_race"` // Optional
 	ArmorClass int             `json:"armor_class"`
 	Speed      int             `json:"speed"`
-}
-
-type Note struct {
-	GameID  string `json:"game_id"`
-	UserID  string `json:"user_id"`
-	Content string `json:"content"`
+	ArchivedAt *time.Time      `json:"archived_at,omitempty"` // Set when the owning player left the game
+	Conditions []Condition     `json:"conditions,omitempty"`  // Populated by GetCharacter
 }
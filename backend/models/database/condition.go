@@ -0,0 +1,21 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Condition struct {
+	ID           string          `json:"id"`
+	GameID       string          `json:"game_id"`
+	CharacterID  string          `json:"character_id"`
+	Name         string          `json:"name"`
+	Source       string          `json:"source"`
+	Description  string          `json:"description"`
+	Modifiers    json.RawMessage `json:"modifiers"`
+	DurationType string          `json:"duration_type"` // rounds, minutes, permanent
+	Duration     int             `json:"duration"`
+	ExpiresRound *int            `json:"expires_round"`
+	ExpiresAt    *time.Time      `json:"expires_at"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package database
+
+import "time"
+
+type Handout struct {
+	ID            string     `json:"id"`
+	GameID        string     `json:"game_id"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	StorageKey    string     `json:"-"`
+	FileName      string     `json:"file_name"`
+	ContentType   string     `json:"content_type"`
+	Size          int64      `json:"size"`
+	URL           string     `json:"url"` // Authenticated file endpoint
+	RevealedToAll bool       `json:"revealed_to_all"`
+	RevealedTo    []string   `json:"revealed_to,omitempty"` // GM only
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RevealedAt    *time.Time `json:"revealed_at"`
+}
@@ -7,5 +7,8 @@ type Invitation struct {
 	GameID    string    `json:"game_id"`
 	UserID    string    `json:"user_id"`
 	UserName  string    `json:"user_name"`
+	GameName  string    `json:"game_name,omitempty"` // Populated for the requesting player's view
+	GmID      string    `json:"gm_id,omitempty"`
+	GmName    string    `json:"gm_name,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
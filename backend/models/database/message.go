@@ -12,5 +12,7 @@ type ChatMessage struct {
 	Content    string    `json:"content"`
 	Type       string    `json:"type"` // "CHAT_GLOBAL", "CHAT_PRIVATE", "EVENT"
 	TargetID   *string   `json:"target_id,omitempty"`
+	IsBot      bool      `json:"is_bot"` // Sent through a personal API token
+	SessionID  *string   `json:"session_id,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 }
@@ -0,0 +1,18 @@
+package database
+
+import "time"
+
+type Note struct {
+	ID         string    `json:"id"`
+	GameID     string    `json:"game_id"`
+	UserID     string    `json:"user_id"` // Author
+	AuthorName string    `json:"author_name"`
+	Title      string    `json:"title"`
+	Folder     string    `json:"folder"` // Slash separated path, "" for the root
+	Tags       []string  `json:"tags"`
+	Content    string    `json:"content"`
+	Visibility string    `json:"visibility"`  // private, gm, party
+	SharedWith []string  `json:"shared_with"` // Users reading the note whatever its visibility
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
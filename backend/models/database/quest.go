@@ -0,0 +1,31 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Quest struct {
+	ID           string           `json:"id"`
+	GameID       string           `json:"game_id"`
+	Title        string           `json:"title"`
+	Description  string           `json:"description"`
+	GiverID      *string          `json:"giver_id"`
+	GiverName    *string          `json:"giver_name"`
+	Status       string           `json:"status"` // available, active, completed, failed
+	Hidden       bool             `json:"hidden"`
+	RewardXP     int              `json:"reward_xp"`
+	RewardMoney  int              `json:"reward_money"`
+	RewardItems  json.RawMessage  `json:"reward_items"`
+	RewardedAt   *time.Time       `json:"rewarded_at"`
+	Objectives   []QuestObjective `json:"objectives"`
+	CharacterIDs []string         `json:"character_ids"` // Characters receiving the rewards
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+type QuestObjective struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RandomTable struct {
+	ID          string             `json:"id"`
+	GameID      string             `json:"game_id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Dice        string             `json:"dice"` // Empty for weighted tables
+	Shared      bool               `json:"shared"`
+	CreatedBy   string             `json:"created_by"`
+	Entries     []RandomTableEntry `json:"entries"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+type RandomTableEntry struct {
+	ID       string          `json:"id"`
+	Text     string          `json:"text"`
+	Weight   int             `json:"weight"`
+	RangeMin *int            `json:"range_min"`
+	RangeMax *int            `json:"range_max"`
+	TableRef *string         `json:"table_ref"` // Nested table rolled when this entry comes up
+	Item     json.RawMessage `json:"item,omitempty"`
+}
+
+// RandomTableRoll is the outcome of a roll, nested rolls included.
+type RandomTableRoll struct {
+	TableID   string            `json:"table_id"`
+	TableName string            `json:"table_name"`
+	Roll      int               `json:"roll"` // Dice total, or the index of the weighted entry
+	Entry     *RandomTableEntry `json:"entry"`
+	Nested    *RandomTableRoll  `json:"nested,omitempty"`
+}
@@ -0,0 +1,28 @@
+package database
+
+import "time"
+
+type GameSession struct {
+	ID              string        `json:"id"`
+	GameID          string        `json:"game_id"`
+	GameName        string        `json:"game_name,omitempty"`
+	Title           string        `json:"title"`
+	Agenda          string        `json:"agenda"`
+	ScheduledAt     time.Time     `json:"scheduled_at"`
+	DurationMinutes int           `json:"duration_minutes"`
+	CreatedBy       string        `json:"created_by"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	CancelledAt     *time.Time    `json:"cancelled_at"`
+	StartedAt       *time.Time    `json:"started_at"`
+	EndedAt         *time.Time    `json:"ended_at"`
+	Recap           string        `json:"recap"`
+	RSVPs           []SessionRSVP `json:"rsvps"`
+}
+
+type SessionRSVP struct {
+	UserID    string    `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type PartyStash struct {
+	GameID       string          `json:"game_id"`
+	Money        int             `json:"money"`
+	Items        json.RawMessage `json:"items"` // Inventory items, each with an "id"
+	FreeWithdraw bool            `json:"free_withdraw"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+type StashLedgerEntry struct {
+	ID            string          `json:"id"`
+	GameID        string          `json:"game_id"`
+	ActorID       string          `json:"actor_id"`
+	ActorName     string          `json:"actor_name"`
+	CharacterID   *string         `json:"character_id"`
+	CharacterName *string         `json:"character_name"`
+	Action        string          `json:"action"` // deposit, withdraw
+	Money         int             `json:"money"`
+	Items         json.RawMessage `json:"items"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package database
+
+import "time"
+
+type Upload struct {
+	ID             string    `json:"id"`
+	OwnerID        string    `json:"owner_id"`
+	Key            string    `json:"key"`
+	URL            string    `json:"url"`
+	Kind           string    `json:"kind"`
+	ContentHash    string    `json:"content_hash"`
+	Size           int64     `json:"size"`
+	ReferenceCount int       `json:"reference_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package database
+
+import "time"
+
+type WikiPage struct {
+	ID        string     `json:"id"`
+	GameID    string     `json:"game_id"`
+	Title     string     `json:"title"`
+	Slug      string     `json:"slug"`
+	Category  string     `json:"category"` // general, location, npc, faction, history
+	Content   string     `json:"content,omitempty"`
+	UpdatedBy string     `json:"updated_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Links     []WikiLink `json:"links,omitempty"`     // Pages this one links to
+	Backlinks []WikiLink `json:"backlinks,omitempty"` // Pages linking to this one
+}
+
+type WikiLink struct {
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	Exists bool   `json:"exists"`
+}
+
+type WikiRevision struct {
+	ID           string    `json:"id"`
+	PageID       string    `json:"page_id"`
+	Title        string    `json:"title"`
+	Category     string    `json:"category"`
+	Content      string    `json:"content"`
+	EditedBy     string    `json:"edited_by"`
+	EditedByName string    `json:"edited_by_name"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type WikiSearchResult struct {
+	Title    string `json:"title"`
+	Slug     string `json:"slug"`
+	Category string `json:"category"`
+	Snippet  string `json:"snippet"`
+}
@@ -0,0 +1,134 @@
+// Package ratelimit implements the named rate-limit policies defined in
+// config.RateLimits. It is shared by the HTTP middleware and the websocket
+// clients.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"questhub/config"
+
+	"golang.org/x/time/rate"
+)
+
+// Result describes the outcome of a rate-limit check, used to fill the
+// RateLimit-* and Retry-After headers.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed, zero when allowed
+}
+
+// Limiter is a token bucket for a single identifier.
+type Limiter struct {
+	policy  config.RateLimitPolicy
+	limiter *rate.Limiter
+}
+
+func NewLimiter(policy config.RateLimitPolicy) *Limiter {
+	interval := policy.Window / time.Duration(policy.Limit)
+	return &Limiter{
+		policy:  policy,
+		limiter: rate.NewLimiter(rate.Every(interval), policy.Limit),
+	}
+}
+
+func (l *Limiter) Allow() Result {
+	now := time.Now()
+	interval := l.policy.Window / time.Duration(l.policy.Limit)
+
+	result := Result{Limit: l.policy.Limit}
+	reservation := l.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Do not consume the token, the request is rejected
+		reservation.CancelAt(now)
+		result.RetryAfter = delay
+	} else {
+		result.Allowed = true
+	}
+
+	tokens := l.limiter.TokensAt(now)
+	result.Remaining = int(math.Max(0, math.Floor(tokens)))
+	result.Reset = time.Duration((float64(l.policy.Limit) - tokens) * float64(interval))
+	return result
+}
+
+type entry struct {
+	limiter  *Limiter
+	lastSeen time.Time
+}
+
+// Store keeps one limiter per identifier for a policy.
+type Store struct {
+	policy  config.RateLimitPolicy
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func (s *Store) Policy() config.RateLimitPolicy {
+	return s.policy
+}
+
+func (s *Store) Allow(identifier string) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.entries[identifier]
+	if !ok {
+		e = &entry{limiter: NewLimiter(s.policy)}
+		s.entries[identifier] = e
+	}
+	e.lastSeen = now
+
+	return e.limiter.Allow()
+}
+
+// cleanup drops identifiers whose bucket has been full for a while.
+func (s *Store) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry := time.Now().Add(-2 * s.policy.Window)
+	for key, e := range s.entries {
+		if e.lastSeen.Before(expiry) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+var (
+	stores   = make(map[string]*Store)
+	storesMu sync.Mutex
+)
+
+// Get returns the store for a named policy, creating it on first use.
+// Unknown names panic so a typo is caught at startup when routes are built.
+func Get(name string) *Store {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if store, ok := stores[name]; ok {
+		return store
+	}
+
+	policy, ok := config.RateLimits[name]
+	if !ok {
+		panic("unknown rate limit policy: " + name)
+	}
+
+	store := &Store{policy: policy, entries: make(map[string]*entry)}
+	stores[name] = store
+	go func() {
+		ticker := time.NewTicker(time.Minute + 2*policy.Window)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.cleanup()
+		}
+	}()
+	return store
+}
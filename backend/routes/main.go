@@ -6,4 +6,5 @@ func SetupRoutes(e *echo.Echo) {
 	initTableRoutes(e)
 	initUploadRoutes(e)
 	initUserRoutes(e)
+	initRandomTableRoutes(e)
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"questhub/controller"
+	"questhub/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+func initRandomTableRoutes(e *echo.Echo) {
+	g := e.Group("/random-tables", middleware.JWTMiddleware)
+
+	g.GET("/shared", controller.GetSharedRandomTables)
+}
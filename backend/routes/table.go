@@ -3,17 +3,24 @@ package routes
 import (
 	"questhub/controller"
 	"questhub/middleware"
+	"questhub/service"
 
 	"github.com/labstack/echo/v4"
-	echoMiddleware "github.com/labstack/echo/v4/middleware"
 )
 
 func initTableRoutes(e *echo.Echo) {
 	g := e.Group("/table", middleware.JWTMiddleware)
 
 	g.POST("", controller.CreateTable)
-	g.GET("", controller.GetGames)
-	g.POST("/join", controller.JoinTable)
+	middleware.AllowAPIToken(g.GET("", controller.GetGames), service.ScopeReadGame)
+	g.POST("/join", controller.JoinTable, middleware.RateLimit("join"))
+	// Leaving stays possible while the game is paused, so it skips CheckGameState
+	g.POST("/:id/leave", controller.LeaveTable)
+	// Sessions are scheduled between games, answering must work while paused
+	g.PUT("/:id/sessions/:sessionId/rsvp", controller.RSVPSession)
+
+	// Calendar feeds also accept a calendar:read API token in the URL
+	middleware.AllowAPIToken(e.GET("/table/:id/sessions.ics", controller.GetTableCalendar, middleware.CalendarFeedMiddleware), service.ScopeReadCalendar)
 
 	// Group for game-specific routes with state check
 	// Applies CheckGameState:
@@ -24,12 +31,13 @@ func initTableRoutes(e *echo.Echo) {
 	// - Blocks if State is "paused"
 	gameGroup := g.Group("/:id", middleware.CheckGameState)
 
-	gameGroup.GET("", controller.GetTable)
-	gameGroup.GET("/players", controller.GetGamePlayers)
-	gameGroup.GET("/characters", controller.GetGameCharacters)
-	gameGroup.POST("/chat", controller.SendMessage)
-	gameGroup.POST("/chat", controller.SendMessage)
-	gameGroup.GET("/roll", controller.RollDice, echoMiddleware.RateLimiterWithConfig(middleware.DiceRollRateLimitConfig))
+	// Routes wrapped in AllowAPIToken also accept personal access tokens with the given scope
+
+	middleware.AllowAPIToken(gameGroup.GET("", controller.GetTable), service.ScopeReadGame)
+	middleware.AllowAPIToken(gameGroup.GET("/players", controller.GetGamePlayers), service.ScopeReadGame)
+	middleware.AllowAPIToken(gameGroup.GET("/characters", controller.GetGameCharacters), service.ScopeReadGame)
+	middleware.AllowAPIToken(gameGroup.POST("/chat", controller.SendMessage, middleware.RateLimit("chat")), service.ScopePostChat)
+	middleware.AllowAPIToken(gameGroup.GET("/roll", controller.RollDice, middleware.RateLimit("dice")), service.ScopeRollDice)
 
 	// GM only routes (RequireGM applies ON TOP of CheckGameState if we nest, or we can separate)
 	// If we use gameGroup.Group, CheckGameState runs first. GM is bypassed in CheckGameState, so it's fine.
@@ -53,18 +61,85 @@ func initTableRoutes(e *echo.Echo) {
 	gmGroup.POST("/invite-code", controller.RegenerateInviteCode)
 	gmGroup.POST("/invite-code", controller.RegenerateInviteCode)
 	gmGroup.DELETE("/players/:userId", controller.RemovePlayer)
+	gmGroup.GET("/bans", controller.GetGameBans)
+	gmGroup.POST("/bans/:userId", controller.BanPlayer)
+	gmGroup.DELETE("/bans/:userId", controller.UnbanPlayer)
 	gmGroup.GET("/monsters", controller.GetGameMonsters)
 	gmGroup.POST("/characters", controller.CreateCharacter)
 	gmGroup.PUT("/characters/:charId", controller.UpdateCharacter)
 	gmGroup.DELETE("/characters/:charId", controller.DeleteCharacter)
 	gmGroup.POST("/characters/:charId/assign", controller.AssignCharacter)
+	gmGroup.POST("/characters/:charId/conditions", controller.ApplyCondition)
+	gmGroup.DELETE("/characters/:charId/conditions/:conditionId", controller.RemoveCondition)
+	gmGroup.GET("/combat", controller.GetCombatRound)
+	gmGroup.POST("/combat/next-round", controller.NextCombatRound)
+	gmGroup.DELETE("/combat", controller.EndCombat)
 	gmGroup.PUT("/state", controller.UpdateTableState)
+	gmGroup.GET("/audit", controller.GetAuditLog)
+	gmGroup.POST("/handouts", controller.CreateHandout, middleware.RateLimit("upload"))
+	gmGroup.POST("/handouts/:handoutId/reveal", controller.RevealHandout)
+	gmGroup.DELETE("/handouts/:handoutId/reveal", controller.HideHandout)
+	gmGroup.DELETE("/handouts/:handoutId", controller.DeleteHandout)
+	gmGroup.POST("/sessions", controller.CreateSession)
+	gmGroup.PUT("/sessions/:sessionId", controller.UpdateSession)
+	gmGroup.DELETE("/sessions/:sessionId", controller.CancelSession)
+	gmGroup.PUT("/sessions/:sessionId/recap", controller.UpdateSessionRecap)
+	gmGroup.POST("/maps", controller.CreateBattleMap)
+	gmGroup.PUT("/maps/:mapId", controller.UpdateBattleMap)
+	gmGroup.DELETE("/maps/:mapId", controller.DeleteBattleMap)
+	gmGroup.POST("/maps/:mapId/tokens", controller.AddMapToken)
+	gmGroup.PUT("/maps/:mapId/tokens/:tokenId", controller.UpdateMapToken)
+	gmGroup.DELETE("/maps/:mapId/tokens/:tokenId", controller.RemoveMapToken)
+	gmGroup.POST("/maps/:mapId/fog/reveal", controller.RevealFog)
+	gmGroup.POST("/maps/:mapId/fog/hide", controller.HideFog)
+	gmGroup.POST("/quests", controller.CreateQuest)
+	gmGroup.PUT("/quests/:questId", controller.UpdateQuest)
+	gmGroup.PUT("/quests/:questId/status", controller.UpdateQuestStatus)
+	gmGroup.PUT("/quests/:questId/objectives/:objectiveId", controller.UpdateQuestObjective)
+	gmGroup.DELETE("/quests/:questId", controller.DeleteQuest)
+	gmGroup.POST("/xp", controller.AwardXP)
+	gmGroup.PUT("/levels", controller.UpdateLevelThresholds)
+	gmGroup.GET("/random-tables", controller.GetRandomTables)
+	gmGroup.POST("/random-tables", controller.CreateRandomTable)
+	gmGroup.POST("/random-tables/import", controller.ImportRandomTable)
+	gmGroup.GET("/random-tables/:tableId", controller.GetRandomTable)
+	gmGroup.PUT("/random-tables/:tableId", controller.UpdateRandomTable)
+	gmGroup.DELETE("/random-tables/:tableId", controller.DeleteRandomTable)
+	gmGroup.POST("/random-tables/:tableId/roll", controller.RollRandomTable, middleware.RateLimit("dice"))
+	gmGroup.PUT("/stash/settings", controller.UpdateStashSettings)
+	gmGroup.POST("/wiki", controller.CreateWikiPage)
+	gmGroup.PUT("/wiki/:slug", controller.UpdateWikiPage)
+	gmGroup.DELETE("/wiki/:slug", controller.DeleteWikiPage)
+	gmGroup.GET("/wiki/:slug/revisions", controller.GetWikiRevisions)
+	gmGroup.POST("/wiki/:slug/revisions/:revisionId/restore", controller.RestoreWikiRevision)
 
 	// Mixed access routes (GM or Owner) - handled in controller
 	// These should also be subject to Game State check (e.g. updating notes)
 	// We use gameGroup which has CheckGameState
-	gameGroup.GET("/characters/:charId", controller.GetCharacter)
+	middleware.AllowAPIToken(gameGroup.GET("/characters/:charId", controller.GetCharacter), service.ScopeReadGame)
 	gameGroup.GET("/characters/:charId/notes", controller.GetCharacterNotes)
 	gameGroup.PUT("/characters/:charId/notes", controller.UpdateCharacterNotes)
-	gameGroup.GET("/chat", controller.GetChatHistory)
+	middleware.AllowAPIToken(gameGroup.GET("/chat", controller.GetChatHistory), service.ScopeReadGame)
+	gameGroup.GET("/handouts", controller.GetHandouts)
+	gameGroup.GET("/handouts/:handoutId/file", controller.GetHandoutFile)
+	gameGroup.GET("/sessions", controller.GetSessions)
+	gameGroup.GET("/sessions/:sessionId/transcript", controller.GetSessionTranscript)
+	gameGroup.GET("/maps", controller.GetBattleMaps)
+	gameGroup.GET("/maps/:mapId", controller.GetBattleMap)
+	gameGroup.POST("/maps/:mapId/tokens/:tokenId/move", controller.MoveMapToken)
+	middleware.AllowAPIToken(gameGroup.GET("/quests", controller.GetQuests), service.ScopeReadGame)
+	gameGroup.GET("/quests/:questId", controller.GetQuest)
+	gameGroup.GET("/levels", controller.GetLevelThresholds)
+	middleware.AllowAPIToken(gameGroup.GET("/stash", controller.GetStash), service.ScopeReadGame)
+	gameGroup.GET("/stash/ledger", controller.GetStashLedger)
+	gameGroup.POST("/stash/deposit", controller.DepositToStash)
+	gameGroup.POST("/stash/withdraw", controller.WithdrawFromStash)
+	middleware.AllowAPIToken(gameGroup.GET("/wiki", controller.GetWikiPages), service.ScopeReadGame)
+	gameGroup.GET("/wiki/search", controller.SearchWiki)
+	gameGroup.GET("/wiki/:slug", controller.GetWikiPage)
+	middleware.AllowAPIToken(gameGroup.GET("/notes", controller.GetNotes), service.ScopeReadGame)
+	gameGroup.POST("/notes", controller.CreateNote)
+	gameGroup.GET("/notes/:noteId", controller.GetNote)
+	gameGroup.PUT("/notes/:noteId", controller.UpdateNote)
+	gameGroup.DELETE("/notes/:noteId", controller.DeleteNote)
 }
@@ -10,5 +10,5 @@ import (
 func initUploadRoutes(e *echo.Echo) {
 	g := e.Group("/upload", middleware.JWTMiddleware)
 
-	g.POST("/image", controller.UploadImage)
+	g.POST("/image", controller.UploadImage, middleware.RateLimit("upload"))
 }
@@ -3,13 +3,23 @@ package routes
 import (
 	"questhub/controller"
 	"questhub/middleware"
+	"questhub/service"
 
 	"github.com/labstack/echo/v4"
 )
 
 func initUserRoutes(e *echo.Echo) {
+	// Calendar feeds also accept a calendar:read API token in the URL
+	middleware.AllowAPIToken(e.GET("/user/calendar.ics", controller.GetUserCalendar, middleware.CalendarFeedMiddleware), service.ScopeReadCalendar)
+
 	g := e.Group("/user", middleware.JWTMiddleware)
 
 	g.GET("/stats", controller.GetUserStats)
 	g.GET("/campaigns", controller.GetUserCampaigns)
+	g.GET("/storage", controller.GetUserStorage)
+	g.GET("/invitations", controller.GetUserInvitations)
+	g.DELETE("/invitations/:invitationId", controller.CancelInvitation)
+	g.GET("/tokens", controller.GetAPITokens)
+	g.POST("/tokens", controller.CreateAPIToken)
+	g.DELETE("/tokens/:tokenId", controller.RevokeAPIToken)
 }
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// APITokenPrefix marks personal access tokens so they can be told apart from JWTs.
+const APITokenPrefix = "qh_pat_"
+
+// Scopes that can be granted to a personal access token
+const (
+	ScopeReadGame = "game:read"
+	ScopePostChat = "chat:write"
+	ScopeRollDice = "dice:roll"
+	// Calendar feeds can pass the token as ?token= since calendar apps cannot set headers
+	ScopeReadCalendar = "calendar:read"
+)
+
+var validScopes = map[string]bool{
+	ScopeReadGame:     true,
+	ScopePostChat:     true,
+	ScopeRollDice:     true,
+	ScopeReadCalendar: true,
+}
+
+var ErrInvalidAPIToken = errors.New("invalid api token")
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsValidScope(scope string) bool {
+	return validScopes[scope]
+}
+
+// CreateAPIToken generates a new token for the user. The plain token is only
+// returned here; the database keeps its hash.
+func CreateAPIToken(userID, name string, scopes []string, expiresAt *time.Time) (*model.APIToken, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	plain := APITokenPrefix + hex.EncodeToString(bytes)
+
+	token := &model.APIToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: plain[:len(APITokenPrefix)+6],
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}
+
+	query := `
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := database.DB.QueryRow(context.Background(), query, userID, name, hashAPIToken(plain), token.TokenPrefix, scopes, expiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, plain, nil
+}
+
+func GetUserAPITokens(userID string) ([]model.APIToken, error) {
+	tokens := []model.APIToken{}
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, created_at, last_used_at, expires_at, revoked_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := database.DB.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token model.APIToken
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scopes, &token.CreatedAt, &token.LastUsedAt, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+func RevokeAPIToken(tokenID, userID string) error {
+	query := `UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := database.DB.Exec(context.Background(), query, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("token not found")
+	}
+	return nil
+}
+
+// AuthenticateAPIToken resolves a plain token to its record and the owner's name.
+func AuthenticateAPIToken(plain string) (*model.APIToken, string, error) {
+	if !strings.HasPrefix(plain, APITokenPrefix) {
+		return nil, "", ErrInvalidAPIToken
+	}
+
+	token := &model.APIToken{}
+	var userName string
+	query := `
+		UPDATE api_tokens t
+		SET last_used_at = NOW()
+		FROM "user" u
+		WHERE t.user_id = u.id AND t.token_hash = $1
+		AND t.revoked_at IS NULL
+		AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		RETURNING t.id, t.user_id, t.name, t.token_prefix, t.scopes, t.created_at, t.last_used_at, t.expires_at, u.name
+	`
+	err := database.DB.QueryRow(context.Background(), query, hashAPIToken(plain)).Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scopes, &token.CreatedAt, &token.LastUsedAt, &token.ExpiresAt, &userName,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, "", ErrInvalidAPIToken
+		}
+		return nil, "", err
+	}
+
+	return token, userName, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"questhub/database"
+	model "questhub/models/database"
+)
+
+// Audited GM actions
+const (
+	AuditTableDelete       = "TABLE_DELETE"
+	AuditTableState        = "TABLE_STATE_UPDATE"
+	AuditInviteCode        = "INVITE_CODE_REGENERATE"
+	AuditInvitationAccept  = "INVITATION_ACCEPT"
+	AuditInvitationDecline = "INVITATION_DECLINE"
+	AuditPlayerRemove      = "PLAYER_REMOVE"
+	AuditPlayerBan         = "PLAYER_BAN"
+	AuditPlayerUnban       = "PLAYER_UNBAN"
+	AuditCharacterCreate   = "CHARACTER_CREATE"
+	AuditCharacterUpdate   = "CHARACTER_UPDATE"
+	AuditCharacterDelete   = "CHARACTER_DELETE"
+	AuditCharacterAssign   = "CHARACTER_ASSIGN"
+	AuditHandoutCreate     = "HANDOUT_CREATE"
+	AuditHandoutReveal     = "HANDOUT_REVEAL"
+	AuditHandoutHide       = "HANDOUT_HIDE"
+	AuditHandoutDelete     = "HANDOUT_DELETE"
+	AuditQuestStatus       = "QUEST_STATUS_UPDATE"
+	AuditQuestDelete       = "QUEST_DELETE"
+	AuditXPAward           = "XP_AWARD"
+	AuditLevelThresholds   = "LEVEL_THRESHOLDS_UPDATE"
+	AuditConditionApply    = "CONDITION_APPLY"
+	AuditConditionRemove   = "CONDITION_REMOVE"
+	AuditStashSettings     = "STASH_SETTINGS_UPDATE"
+	AuditWikiCreate        = "WIKI_CREATE"
+	AuditWikiUpdate        = "WIKI_UPDATE"
+	AuditWikiDelete        = "WIKI_DELETE"
+	AuditWikiRestore       = "WIKI_REVISION_RESTORE"
+	AuditMapCreate         = "MAP_CREATE"
+	AuditMapUpdate         = "MAP_UPDATE"
+	AuditMapDelete         = "MAP_DELETE"
+	AuditMapTokenAdd       = "MAP_TOKEN_ADD"
+	AuditMapTokenUpdate    = "MAP_TOKEN_UPDATE"
+	AuditMapTokenRemove    = "MAP_TOKEN_REMOVE"
+	AuditMapFogReveal      = "MAP_FOG_REVEAL"
+	AuditMapFogHide        = "MAP_FOG_HIDE"
+	AuditSessionCreate     = "SESSION_CREATE"
+	AuditSessionUpdate     = "SESSION_UPDATE"
+	AuditSessionCancel     = "SESSION_CANCEL"
+	AuditSessionRecap      = "SESSION_RECAP_UPDATE"
+	AuditQuestCreate       = "QUEST_CREATE"
+	AuditQuestUpdate       = "QUEST_UPDATE"
+	AuditQuestObjective    = "QUEST_OBJECTIVE_UPDATE"
+	AuditCombatRound       = "COMBAT_ROUND_ADVANCE"
+	AuditCombatEnd         = "COMBAT_END"
+	AuditRandomTableCreate = "RANDOM_TABLE_CREATE"
+	AuditRandomTableImport = "RANDOM_TABLE_IMPORT"
+	AuditRandomTableUpdate = "RANDOM_TABLE_UPDATE"
+	AuditRandomTableDelete = "RANDOM_TABLE_DELETE"
+	AuditRandomTableLoot   = "RANDOM_TABLE_LOOT"
+)
+
+// RecordAudit appends an entry to the audit log. Snapshots are marshalled to
+// JSON; nil snapshots are stored as NULL.
+func RecordAudit(gameID, actorID, action, targetType, targetID string, before, after any) error {
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (game_id, actor_id, action, target_type, target_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = database.DB.Exec(context.Background(), query, gameID, actorID, action, targetType, targetID, beforeJSON, afterJSON)
+	return err
+}
+
+func marshalSnapshot(snapshot any) ([]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+type AuditFilter struct {
+	ActorID string
+	Action  string
+	Before  *time.Time // Only entries older than this, for pagination
+	Limit   int
+}
+
+func GetAuditLog(gameID string, filter AuditFilter) ([]model.AuditEntry, error) {
+	conditions := []string{"a.game_id = $1"}
+	args := []any{gameID}
+
+	if filter.ActorID != "" {
+		args = append(args, filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("a.actor_id = $%d", len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("a.action = $%d", len(args)))
+	}
+	if filter.Before != nil {
+		args = append(args, *filter.Before)
+		conditions = append(conditions, fmt.Sprintf("a.created_at < $%d", len(args)))
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT a.id, a.game_id, a.actor_id, COALESCE(u.name, ''), a.action, a.target_type, a.target_id, a.before, a.after, a.created_at
+		FROM audit_log a
+		LEFT JOIN "user" u ON a.actor_id = u.id
+		WHERE %s
+		ORDER BY a.created_at DESC
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := database.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var entry model.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.GameID, &entry.ActorID, &entry.ActorName, &entry.Action, &entry.TargetType, &entry.TargetID, &entry.Before, &entry.After, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questhub/database"
+	model "questhub/models/database"
+)
+
+var ErrUserBanned = errors.New("user is banned from this game")
+
+// IsBanned reports whether the user has an active (non-expired) ban on the game.
+func IsBanned(gameID, userID string) (bool, error) {
+	var banned bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM game_bans
+			WHERE game_id = $1 AND user_id = $2
+			AND (expires_at IS NULL OR expires_at > NOW())
+		)
+	`
+	err := database.DB.QueryRow(context.Background(), query, gameID, userID).Scan(&banned)
+	return banned, err
+}
+
+// BanPlayer removes the user from the game (membership and pending request)
+// and records the ban. Banning an already banned user replaces the previous ban.
+func BanPlayer(gameID, userID, bannedBy, reason string, expiresAt *time.Time) error {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM game_players WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM game_invitations WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO game_bans (game_id, user_id, banned_by, reason, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (game_id, user_id) DO UPDATE
+		SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP
+	`
+	_, err = tx.Exec(ctx, query, gameID, userID, bannedBy, reason, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func UnbanPlayer(gameID, userID string) error {
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM game_bans WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("ban not found")
+	}
+	return nil
+}
+
+func GetGameBans(gameID string) ([]model.Ban, error) {
+	bans := []model.Ban{}
+	query := `
+		SELECT b.game_id, b.user_id, u.name, b.banned_by, b.reason, b.expires_at, b.created_at
+		FROM game_bans b
+		JOIN "user" u ON b.user_id = u.id
+		WHERE b.game_id = $1
+		AND (b.expires_at IS NULL OR b.expires_at > NOW())
+		ORDER BY b.created_at DESC
+	`
+	rows, err := database.DB.Query(context.Background(), query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ban model.Ban
+		if err := rows.Scan(&ban.GameID, &ban.UserID, &ban.UserName, &ban.BannedBy, &ban.Reason, &ban.ExpiresAt, &ban.CreatedAt); err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
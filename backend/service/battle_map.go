@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"questhub/database"
+	model "questhub/models/database"
+	"questhub/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidMap       = errors.New("map name, width and height are required")
+	ErrTokenForbidden   = errors.New("only the GM or the character's player can move this token")
+	ErrTokenOutOfBounds = errors.New("token position is outside the map")
+)
+
+// Maps are capped so a typo cannot create a million-cell grid
+const maxMapCells = 200
+
+type BattleMapInput struct {
+	Name          string `json:"name"`
+	BackgroundURL string `json:"background_url"`
+	GridSize      int    `json:"grid_size"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	IsActive      bool   `json:"is_active"`
+	FogEnabled    bool   `json:"fog_enabled"`
+}
+
+type MapTokenInput struct {
+	CharacterID *string  `json:"character_id"`
+	Label       string   `json:"label"`
+	X           int      `json:"x"`
+	Y           int      `json:"y"`
+	Size        int      `json:"size"`
+	Color       string   `json:"color"`
+	Hidden      bool     `json:"hidden"`
+	VisibleTo   []string `json:"visible_to"` // Players who still see a hidden token
+}
+
+func (in *BattleMapInput) validate() error {
+	if in.Name == "" || in.Width <= 0 || in.Height <= 0 || in.Width > maxMapCells || in.Height > maxMapCells {
+		return ErrInvalidMap
+	}
+	if in.GridSize <= 0 {
+		in.GridSize = 50
+	}
+	in.BackgroundURL = storage.KeyFromURL(in.BackgroundURL)
+	return nil
+}
+
+const battleMapColumns = `id, game_id, name, COALESCE(background_url, ''), grid_size, width, height, is_active, fog_enabled, revealed_cells, created_at, updated_at`
+
+func scanBattleMap(row pgx.Row) (*model.BattleMap, error) {
+	m := &model.BattleMap{}
+	if err := row.Scan(&m.ID, &m.GameID, &m.Name, &m.BackgroundURL, &m.GridSize, &m.Width, &m.Height, &m.IsActive, &m.FogEnabled, &m.RevealedCells, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	m.BackgroundURL = storage.PublicURL(m.BackgroundURL)
+	return m, nil
+}
+
+// Token label and owner come from the linked character when there is one
+const mapTokenQuery = `
+	SELECT t.id, t.map_id, t.character_id, gc.user_id, COALESCE(NULLIF(t.label, ''), c.name, ''), COALESCE(c.avatar_url, ''),
+	       t.x, t.y, t.size, t.color, t.hidden, t.visible_to, t.updated_at
+	FROM map_tokens t
+	JOIN battle_maps m ON m.id = t.map_id
+	LEFT JOIN characters c ON c.id = t.character_id
+	LEFT JOIN game_characters gc ON gc.character_id = t.character_id AND gc.game_id = m.game_id
+`
+
+func scanMapToken(row pgx.Row) (*model.MapToken, error) {
+	t := &model.MapToken{}
+	if err := row.Scan(&t.ID, &t.MapID, &t.CharacterID, &t.OwnerID, &t.Label, &t.AvatarURL, &t.X, &t.Y, &t.Size, &t.Color, &t.Hidden, &t.VisibleTo, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	t.AvatarURL = storage.PublicURL(t.AvatarURL)
+	return t, nil
+}
+
+// GetBattleMaps lists the maps of a game without their tokens. Players only see the active map.
+func GetBattleMaps(gameID string, activeOnly bool) ([]model.BattleMap, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT `+battleMapColumns+`
+		FROM battle_maps
+		WHERE game_id = $1 AND (NOT $2 OR is_active)
+		ORDER BY created_at DESC
+	`, gameID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	maps := []model.BattleMap{}
+	for rows.Next() {
+		m, err := scanBattleMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, *m)
+	}
+	return maps, rows.Err()
+}
+
+// GetBattleMap returns a map with its tokens.
+func GetBattleMap(gameID, mapID string) (*model.BattleMap, error) {
+	m, err := scanBattleMap(database.DB.QueryRow(context.Background(),
+		"SELECT "+battleMapColumns+" FROM battle_maps WHERE game_id = $1 AND id = $2", gameID, mapID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("map not found")
+		}
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(context.Background(), mapTokenQuery+" WHERE t.map_id = $1 ORDER BY t.created_at", mapID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m.Tokens = []model.MapToken{}
+	for rows.Next() {
+		token, err := scanMapToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		m.Tokens = append(m.Tokens, *token)
+	}
+	return m, rows.Err()
+}
+
+// deactivateOtherMaps keeps a single active map per game.
+func deactivateOtherMaps(tx pgx.Tx, gameID, mapID string) error {
+	_, err := tx.Exec(context.Background(),
+		"UPDATE battle_maps SET is_active = FALSE WHERE game_id = $1 AND id != $2 AND is_active", gameID, mapID)
+	return err
+}
+
+// CreateBattleMap adds a map. The background must be an upload of userID.
+func CreateBattleMap(gameID, userID string, input BattleMapInput) (*model.BattleMap, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	if err := CheckUploadKey(input.BackgroundURL, userID, nil); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	m, err := scanBattleMap(tx.QueryRow(context.Background(), `
+		INSERT INTO battle_maps (game_id, name, background_url, grid_size, width, height, is_active, fog_enabled)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+		RETURNING `+battleMapColumns,
+		gameID, input.Name, input.BackgroundURL, input.GridSize, input.Width, input.Height, input.IsActive, input.FogEnabled))
+	if err != nil {
+		return nil, err
+	}
+
+	if input.IsActive {
+		if err := deactivateOtherMaps(tx, gameID, m.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	m.Tokens = []model.MapToken{}
+	return m, nil
+}
+
+// UpdateBattleMap changes a map. Tokens left outside a shrunk map are moved back to its edge.
+// A new background must be an upload of userID.
+func UpdateBattleMap(gameID, mapID, userID string, input BattleMapInput) (*model.BattleMap, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	// Revealed cells are kept at the same coordinates when the map is resized
+	var oldWidth, oldHeight int
+	var revealed []byte
+	var oldBackground string
+	err = tx.QueryRow(context.Background(),
+		"SELECT width, height, revealed_cells, COALESCE(background_url, '') FROM battle_maps WHERE game_id = $1 AND id = $2 FOR UPDATE",
+		gameID, mapID).Scan(&oldWidth, &oldHeight, &revealed, &oldBackground)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("map not found")
+		}
+		return nil, err
+	}
+	if err := CheckUploadKey(input.BackgroundURL, userID, KeptUploads([]string{oldBackground})); err != nil {
+		return nil, err
+	}
+	if oldWidth != input.Width || oldHeight != input.Height {
+		revealed = resizeFog(revealed, oldWidth, oldHeight, input.Width, input.Height)
+	}
+
+	_, err = tx.Exec(context.Background(), `
+		UPDATE battle_maps
+		SET name = $1, background_url = NULLIF($2, ''), grid_size = $3, width = $4, height = $5, is_active = $6,
+		    fog_enabled = $7, revealed_cells = $8, updated_at = NOW()
+		WHERE id = $9
+	`, input.Name, input.BackgroundURL, input.GridSize, input.Width, input.Height, input.IsActive, input.FogEnabled, revealed, mapID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(context.Background(), `
+		UPDATE map_tokens
+		SET x = LEAST(x, $1 - 1), y = LEAST(y, $2 - 1), updated_at = NOW()
+		WHERE map_id = $3 AND (x >= $1 OR y >= $2)
+	`, input.Width, input.Height, mapID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.IsActive {
+		if err := deactivateOtherMaps(tx, gameID, mapID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetBattleMap(gameID, mapID)
+}
+
+func DeleteBattleMap(gameID, mapID string) error {
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM battle_maps WHERE game_id = $1 AND id = $2", gameID, mapID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("map not found")
+	}
+	return nil
+}
+
+func AddMapToken(gameID, mapID string, input MapTokenInput) (*model.MapToken, error) {
+	m, err := scanBattleMap(database.DB.QueryRow(context.Background(),
+		"SELECT "+battleMapColumns+" FROM battle_maps WHERE game_id = $1 AND id = $2", gameID, mapID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("map not found")
+		}
+		return nil, err
+	}
+
+	if input.Size <= 0 {
+		input.Size = 1
+	}
+	if input.X < 0 || input.Y < 0 || input.X >= m.Width || input.Y >= m.Height {
+		return nil, ErrTokenOutOfBounds
+	}
+
+	// Characters must belong to the game
+	if input.CharacterID != nil && *input.CharacterID != "" {
+		var exists bool
+		err := database.DB.QueryRow(context.Background(),
+			"SELECT EXISTS (SELECT 1 FROM game_characters WHERE game_id = $1 AND character_id = $2)",
+			gameID, *input.CharacterID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("character not found")
+		}
+	} else {
+		input.CharacterID = nil
+	}
+
+	if input.VisibleTo == nil {
+		input.VisibleTo = []string{}
+	}
+
+	var tokenID string
+	err = database.DB.QueryRow(context.Background(), `
+		INSERT INTO map_tokens (map_id, character_id, label, x, y, size, color, hidden, visible_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, mapID, input.CharacterID, input.Label, input.X, input.Y, input.Size, input.Color, input.Hidden, input.VisibleTo).Scan(&tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetMapToken(gameID, tokenID)
+}
+
+func GetMapToken(gameID, tokenID string) (*model.MapToken, error) {
+	token, err := scanMapToken(database.DB.QueryRow(context.Background(),
+		mapTokenQuery+" WHERE m.game_id = $1 AND t.id = $2", gameID, tokenID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("token not found")
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// RemoveMapToken deletes a token of the map and returns it as it was.
+func RemoveMapToken(gameID, mapID, tokenID string) (*model.MapToken, error) {
+	token, err := GetMapToken(gameID, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if token.MapID != mapID {
+		return nil, errors.New("token not found")
+	}
+
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM map_tokens WHERE id = $1 AND map_id = $2", tokenID, mapID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("token not found")
+	}
+	return token, nil
+}
+
+// UpdateMapToken changes how a token looks and who sees it. Position changes go through MoveToken.
+func UpdateMapToken(gameID, mapID, tokenID string, input MapTokenInput) (*model.MapToken, *model.MapToken, error) {
+	previous, err := GetMapToken(gameID, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if previous.MapID != mapID {
+		return nil, nil, errors.New("token not found")
+	}
+
+	if input.Size <= 0 {
+		input.Size = 1
+	}
+	if input.VisibleTo == nil {
+		input.VisibleTo = []string{}
+	}
+
+	_, err = database.DB.Exec(context.Background(), `
+		UPDATE map_tokens
+		SET label = $1, size = $2, color = $3, hidden = $4, visible_to = $5, updated_at = NOW()
+		WHERE id = $6
+	`, input.Label, input.Size, input.Color, input.Hidden, input.VisibleTo, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := GetMapToken(gameID, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, previous, nil
+}
+
+// MoveToken moves a token to a cell and returns it with its previous state.
+// The GM can move any token, players only the tokens of their own character
+// on the active map.
+func MoveToken(gameID, mapID, tokenID, userID string, x, y int) (*model.MapToken, *model.MapToken, error) {
+	var gmID string
+	var width, height int
+	var isActive bool
+	var ownerID *string
+	err := database.DB.QueryRow(context.Background(), `
+		SELECT g.gm_id, m.width, m.height, m.is_active, gc.user_id
+		FROM map_tokens t
+		JOIN battle_maps m ON m.id = t.map_id
+		JOIN games g ON g.id = m.game_id
+		LEFT JOIN game_characters gc ON gc.character_id = t.character_id AND gc.game_id = m.game_id
+		WHERE m.game_id = $1 AND m.id = $2 AND t.id = $3
+	`, gameID, mapID, tokenID).Scan(&gmID, &width, &height, &isActive, &ownerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, errors.New("token not found")
+		}
+		return nil, nil, err
+	}
+
+	// Players only play on the map they are shown
+	if userID != gmID && (ownerID == nil || *ownerID != userID || !isActive) {
+		return nil, nil, ErrTokenForbidden
+	}
+	if x < 0 || y < 0 || x >= width || y >= height {
+		return nil, nil, ErrTokenOutOfBounds
+	}
+
+	previous, err := GetMapToken(gameID, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = database.DB.Exec(context.Background(),
+		"UPDATE map_tokens SET x = $1, y = $2, updated_at = NOW() WHERE id = $3", x, y, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := GetMapToken(gameID, tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, previous, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	model "questhub/models/database"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// icsEscape escapes TEXT values (RFC 5545 section 3.3.11).
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// writeICSLine folds lines longer than 75 octets without splitting UTF-8 characters.
+func writeICSLine(buf *bytes.Buffer, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+// BuildCalendar renders sessions as an iCalendar feed.
+func BuildCalendar(name string, sessions []model.GameSession) []byte {
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//QuestHub//Sessions//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "METHOD:PUBLISH")
+	writeICSLine(&buf, "X-WR-CALNAME:"+icsEscape(name))
+
+	for _, s := range sessions {
+		end := s.ScheduledAt.Add(time.Duration(s.DurationMinutes) * time.Minute)
+		summary := s.Title
+		if s.GameName != "" {
+			summary = s.GameName + " - " + s.Title
+		}
+
+		writeICSLine(&buf, "BEGIN:VEVENT")
+		writeICSLine(&buf, fmt.Sprintf("UID:%s@questhub", s.ID))
+		writeICSLine(&buf, "DTSTAMP:"+s.UpdatedAt.UTC().Format(icsTimeFormat))
+		writeICSLine(&buf, "LAST-MODIFIED:"+s.UpdatedAt.UTC().Format(icsTimeFormat))
+		// Calendars only apply updates with a higher sequence
+		writeICSLine(&buf, fmt.Sprintf("SEQUENCE:%d", s.UpdatedAt.Unix()-s.CreatedAt.Unix()))
+		writeICSLine(&buf, "DTSTART:"+s.ScheduledAt.UTC().Format(icsTimeFormat))
+		writeICSLine(&buf, "DTEND:"+end.UTC().Format(icsTimeFormat))
+		writeICSLine(&buf, "SUMMARY:"+icsEscape(summary))
+		if s.Agenda != "" {
+			writeICSLine(&buf, "DESCRIPTION:"+icsEscape(s.Agenda))
+		}
+		if s.CancelledAt != nil {
+			writeICSLine(&buf, "STATUS:CANCELLED")
+		} else {
+			writeICSLine(&buf, "STATUS:CONFIRMED")
+		}
+		writeICSLine(&buf, "END:VEVENT")
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
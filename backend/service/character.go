@@ -18,7 +18,7 @@ func GetUserCharacter(gameID, userID string) (*model.Character, error) {
 	query := `
 		SELECT c.id, gc.game_id, gc.user_id, c.name, c.avatar_url, c.stats, c.inventory, c.is_npc, c.created_at,
 		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.armor_class, c.speed,
-		       c.type, c.sub_race
+		       c.type, c.sub_race, ` + characterLevelColumn + `
 		FROM characters c
 		JOIN game_characters gc ON c.id = gc.character_id
 		WHERE gc.game_id = $1 AND gc.user_id = $2
@@ -45,6 +45,7 @@ func GetUserCharacter(gameID, userID string) (*model.Character, error) {
 		&char.Speed,
 		&char.Type,
 		&char.SubRace,
+		&char.Level,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows || err == pgx.ErrNoRows {
@@ -53,6 +54,7 @@ func GetUserCharacter(gameID, userID string) (*model.Character, error) {
 		log.Printf("[GetUserCharacter] Scan error: %v\n", err)
 		return nil, err
 	}
+	resolveCharacterURLs(char)
 	return char, nil
 }
 
@@ -61,7 +63,8 @@ func GetCharacter(gameID, charID string) (*model.Character, error) {
 	// Join with game_characters to enforce game context and fill game_id/user_id
 	query := `
 		SELECT c.id, gc.game_id, gc.user_id, c.name, c.race, c.max_hp, c.current_hp, COALESCE(c.avatar_url, ''), c.stats, c.inventory, c.is_npc, c.money, c.created_at,
-		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed
+		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed,
+		       ` + characterLevelColumn + `
 		FROM characters c
 		JOIN game_characters gc ON c.id = gc.character_id
 		WHERE gc.game_id = $1 AND c.id = $2
@@ -92,6 +95,7 @@ func GetCharacter(gameID, charID string) (*model.Character, error) {
 		&char.SubRace,
 		&char.ArmorClass,
 		&char.Speed,
+		&char.Level,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -99,21 +103,29 @@ func GetCharacter(gameID, charID string) (*model.Character, error) {
 		}
 		return nil, err
 	}
+	resolveCharacterURLs(char)
+
+	char.Conditions, err = GetCharacterConditions(char.ID)
+	if err != nil {
+		return nil, err
+	}
 	return char, nil
 }
 
-func GetGameCharacters(gameID string) ([]model.Character, error) {
+func GetGameCharacters(gameID string, includeArchived bool) ([]model.Character, error) {
 	characters := []model.Character{}
 	query := `
 		SELECT c.id, gc.game_id, gc.user_id, c.name, c.race, c.max_hp, c.current_hp, COALESCE(c.avatar_url, ''), c.stats, c.inventory, c.is_npc, c.money, c.created_at, u.name,
-		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed
+		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed, gc.archived_at,
+		       ` + characterLevelColumn + `
 		FROM characters c
 		JOIN game_characters gc ON c.id = gc.character_id
 		LEFT JOIN "user" u ON gc.user_id = u.id
 		WHERE gc.game_id = $1 AND (c.type = 'PLAYER' OR c.type = 'NPC')
+		AND ($2 OR gc.archived_at IS NULL)
 		ORDER BY c.created_at DESC
 	`
-	rows, err := database.DB.Query(context.Background(), query, gameID)
+	rows, err := database.DB.Query(context.Background(), query, gameID, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -123,12 +135,14 @@ func GetGameCharacters(gameID string) ([]model.Character, error) {
 		var char model.Character
 		var playerName sql.NullString
 		if err := rows.Scan(&char.ID, &char.GameID, &char.UserID, &char.Name, &char.Race, &char.MaxHP, &char.CurrentHP, &char.AvatarURL, &char.Stats, &char.Inventory, &char.IsNPC, &char.Money, &char.CreatedAt, &playerName,
-			&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed); err != nil {
+			&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed, &char.ArchivedAt,
+			&char.Level); err != nil {
 			return nil, err
 		}
 		if playerName.Valid {
 			char.PlayerName = &playerName.String
 		}
+		resolveCharacterURLs(&char)
 		characters = append(characters, char)
 	}
 
@@ -139,7 +153,8 @@ func GetGameMonsters(gameID string) ([]model.Character, error) {
 	characters := []model.Character{}
 	query := `
 		SELECT c.id, gc.game_id, gc.user_id, c.name, c.race, c.max_hp, c.current_hp, COALESCE(c.avatar_url, ''), c.stats, c.inventory, c.is_npc, c.money, c.created_at,
-		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed
+		       c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed,
+		       ` + characterLevelColumn + `
 		FROM characters c
 		JOIN game_characters gc ON c.id = gc.character_id
 		WHERE gc.game_id = $1 AND c.type = 'MONSTER'
@@ -154,9 +169,11 @@ func GetGameMonsters(gameID string) ([]model.Character, error) {
 	for rows.Next() {
 		var char model.Character
 		if err := rows.Scan(&char.ID, &char.GameID, &char.UserID, &char.Name, &char.Race, &char.MaxHP, &char.CurrentHP, &char.AvatarURL, &char.Stats, &char.Inventory, &char.IsNPC, &char.Money, &char.CreatedAt,
-			&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed); err != nil {
+			&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed,
+			&char.Level); err != nil {
 			return nil, err
 		}
+		resolveCharacterURLs(&char)
 		characters = append(characters, char)
 	}
 
@@ -247,6 +264,13 @@ func CreateCharacter(gameID, userID, name, race string, maxHP int, isNPC bool, a
 		return nil, err
 	}
 
+	thresholds, err := GetLevelThresholds(gameID)
+	if err != nil {
+		return nil, err
+	}
+	char.Level = LevelForXP(thresholds, char.Experience)
+
+	resolveCharacterURLs(char)
 	return char, nil
 }
 
@@ -260,7 +284,8 @@ func UpdateCharacter(id, gameID, name, race string, maxHP int, isNPC bool, avata
 		FROM game_characters gc
 		WHERE c.id = gc.character_id AND c.id = $21 AND gc.game_id = $22
 		RETURNING c.id, gc.game_id, gc.user_id, c.name, c.race, c.max_hp, c.current_hp, COALESCE(c.avatar_url, ''), c.stats, c.inventory, c.is_npc, c.money, c.created_at,
-		          c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed
+		          c.initiative, c.age, c.height, c.weight, c.max_spells, c.spells, c.abilities, c.experience, c.type, c.sub_race, c.armor_class, c.speed,
+		          ` + characterLevelColumn + `
 	`
 
 	char := &model.Character{}
@@ -269,12 +294,14 @@ func UpdateCharacter(id, gameID, name, race string, maxHP int, isNPC bool, avata
 		initiative, age, height, weight, maxSpells, string(spells), abilities, experience, charType, subRace, armorClass, speed,
 		id, gameID,
 	).Scan(&char.ID, &char.GameID, &char.UserID, &char.Name, &char.Race, &char.MaxHP, &char.CurrentHP, &char.AvatarURL, &char.Stats, &char.Inventory, &char.IsNPC, &char.Money, &char.CreatedAt,
-		&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed)
+		&char.Initiative, &char.Age, &char.Height, &char.Weight, &char.MaxSpells, &char.Spells, &char.Abilities, &char.Experience, &char.Type, &char.SubRace, &char.ArmorClass, &char.Speed,
+		&char.Level)
 
 	if err != nil {
 		return nil, err
 	}
 
+	resolveCharacterURLs(char)
 	return char, nil
 }
 
@@ -322,30 +349,6 @@ func AssignCharacterToPlayer(gameID, characterID, playerID string) error {
 	return nil
 }
 
-func GetNotes(gameID, userID string) (string, error) {
-	var content string
-	query := `SELECT content FROM notes WHERE game_id = $1 AND user_id = $2`
-	err := database.DB.QueryRow(context.Background(), query, gameID, userID).Scan(&content)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", nil // No notes found, return empty string
-		}
-		return "", err
-	}
-	return content, nil
-}
-
-func UpdateNotes(gameID, userID, content string) error {
-	query := `
-		INSERT INTO notes (game_id, user_id, content)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (game_id, user_id) DO UPDATE
-		SET content = EXCLUDED.content
-	`
-	_, err := database.DB.Exec(context.Background(), query, gameID, userID, content)
-	return err
-}
-
 func EnsureGMCharacter(gameID, manualGMID string) (*model.Character, error) {
 	log.Printf("[EnsureGMCharacter] Checking for GM char. GameID=%s, UserID=%s\n", gameID, manualGMID)
 	// Check if character already exists for GM
@@ -8,15 +8,15 @@ import (
 
 func SaveMessage(msg model.ChatMessage) error {
 	_, err := database.DB.Exec(context.Background(),
-		`INSERT INTO messages (game_id, sender_id, sender_name, content, type, target_id, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		msg.GameID, msg.SenderID, msg.SenderName, msg.Content, msg.Type, msg.TargetID, msg.CreatedAt)
+		`INSERT INTO messages (game_id, sender_id, sender_name, content, type, target_id, created_at, is_bot, session_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, (`+openSessionQuery+`))`,
+		msg.GameID, msg.SenderID, msg.SenderName, msg.Content, msg.Type, msg.TargetID, msg.CreatedAt, msg.IsBot)
 	return err
 }
 
 func GetGameMessages(gameID, userID string) ([]model.ChatMessage, error) {
 	rows, err := database.DB.Query(context.Background(),
-		`SELECT id, game_id, sender_id, sender_name, content, type, target_id, created_at
+		`SELECT id, game_id, sender_id, sender_name, content, type, target_id, created_at, is_bot, session_id
 		FROM messages
 		WHERE game_id = $1
 		AND (type != 'CHAT_PRIVATE' OR sender_id = $2 OR target_id = $2)
@@ -31,7 +31,7 @@ func GetGameMessages(gameID, userID string) ([]model.ChatMessage, error) {
 	messages := []model.ChatMessage{}
 	for rows.Next() {
 		var msg model.ChatMessage
-		err := rows.Scan(&msg.ID, &msg.GameID, &msg.SenderID, &msg.SenderName, &msg.Content, &msg.Type, &msg.TargetID, &msg.CreatedAt)
+		err := rows.Scan(&msg.ID, &msg.GameID, &msg.SenderID, &msg.SenderName, &msg.Content, &msg.Type, &msg.TargetID, &msg.CreatedAt, &msg.IsBot, &msg.SessionID)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidCondition = errors.New("a name is required, with a positive duration in rounds or minutes unless permanent")
+
+type ConditionInput struct {
+	Name         string          `json:"name"`
+	Source       string          `json:"source"`
+	Description  string          `json:"description"`
+	Modifiers    json.RawMessage `json:"modifiers"`
+	DurationType string          `json:"duration_type"` // rounds, minutes, permanent (default)
+	Duration     int             `json:"duration"`
+}
+
+func (in *ConditionInput) validate() error {
+	if in.DurationType == "" {
+		in.DurationType = "permanent"
+	}
+	if in.Name == "" {
+		return ErrInvalidCondition
+	}
+	switch in.DurationType {
+	case "permanent":
+		in.Duration = 0
+	case "rounds", "minutes":
+		if in.Duration <= 0 {
+			return ErrInvalidCondition
+		}
+	default:
+		return ErrInvalidCondition
+	}
+
+	if len(in.Modifiers) == 0 || string(in.Modifiers) == "null" {
+		in.Modifiers = json.RawMessage("{}")
+	}
+	var modifiers map[string]any
+	if err := json.Unmarshal(in.Modifiers, &modifiers); err != nil {
+		return ErrInvalidCondition
+	}
+	return nil
+}
+
+const conditionColumns = `id, game_id, character_id, name, source, description, modifiers, duration_type, duration, expires_round, expires_at, created_at`
+
+func scanCondition(row pgx.Row) (*model.Condition, error) {
+	c := &model.Condition{}
+	err := row.Scan(&c.ID, &c.GameID, &c.CharacterID, &c.Name, &c.Source, &c.Description, &c.Modifiers, &c.DurationType, &c.Duration, &c.ExpiresRound, &c.ExpiresAt, &c.CreatedAt)
+	return c, err
+}
+
+func queryConditions(query string, args ...any) ([]model.Condition, error) {
+	rows, err := database.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conditions := []model.Condition{}
+	for rows.Next() {
+		c, err := scanCondition(rows)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, *c)
+	}
+	return conditions, rows.Err()
+}
+
+// GetCharacterConditions lists the active conditions of a character. Conditions
+// past their end time are left out even before the expiry job removes them.
+func GetCharacterConditions(characterID string) ([]model.Condition, error) {
+	return queryConditions(`
+		SELECT `+conditionColumns+`
+		FROM character_conditions
+		WHERE character_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at
+	`, characterID)
+}
+
+// ApplyCondition adds a condition to a character. Round durations count from
+// the current combat round.
+func ApplyCondition(gameID, characterID string, input ConditionInput) (*model.Condition, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	condition, err := scanCondition(database.DB.QueryRow(context.Background(), `
+		INSERT INTO character_conditions (game_id, character_id, name, source, description, modifiers, duration_type, duration, expires_round, expires_at)
+		SELECT gc.game_id, gc.character_id, $3, $4, $5, $6, $7, $8,
+		       CASE WHEN $7 = 'rounds' THEN g.combat_round + $8 END,
+		       CASE WHEN $7 = 'minutes' THEN NOW() + make_interval(mins => $8) END
+		FROM game_characters gc
+		JOIN games g ON g.id = gc.game_id
+		WHERE gc.game_id = $1 AND gc.character_id = $2
+		RETURNING `+conditionColumns,
+		gameID, characterID, input.Name, input.Source, input.Description, input.Modifiers, input.DurationType, input.Duration))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("character not found")
+		}
+		return nil, err
+	}
+	return condition, nil
+}
+
+func RemoveCondition(gameID, characterID, conditionID string) error {
+	result, err := database.DB.Exec(context.Background(),
+		"DELETE FROM character_conditions WHERE game_id = $1 AND character_id = $2 AND id = $3",
+		gameID, characterID, conditionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("condition not found")
+	}
+	return nil
+}
+
+func GetCombatRound(gameID string) (int, error) {
+	var round int
+	err := database.DB.QueryRow(context.Background(), "SELECT combat_round FROM games WHERE id = $1", gameID).Scan(&round)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errors.New("game not found")
+	}
+	return round, err
+}
+
+// AdvanceCombatRound moves the game to the next round and removes the
+// conditions ending with it.
+func AdvanceCombatRound(gameID string) (int, []model.Condition, error) {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var round int
+	err = tx.QueryRow(ctx, "UPDATE games SET combat_round = combat_round + 1 WHERE id = $1 RETURNING combat_round", gameID).Scan(&round)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil, errors.New("game not found")
+		}
+		return 0, nil, err
+	}
+
+	expired, err := deleteConditions(tx, "game_id = $1 AND expires_round <= $2", gameID, round)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+	return round, expired, nil
+}
+
+// EndCombat resets the round counter. Conditions counted in rounds only last
+// for the fight and are removed.
+func EndCombat(gameID string) ([]model.Condition, error) {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, "UPDATE games SET combat_round = 0 WHERE id = $1", gameID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("game not found")
+	}
+
+	expired, err := deleteConditions(tx, "game_id = $1 AND duration_type = 'rounds'", gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func deleteConditions(tx pgx.Tx, where string, args ...any) ([]model.Condition, error) {
+	rows, err := tx.Query(context.Background(), "DELETE FROM character_conditions WHERE "+where+" RETURNING "+conditionColumns, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conditions := []model.Condition{}
+	for rows.Next() {
+		c, err := scanCondition(rows)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, *c)
+	}
+	return conditions, rows.Err()
+}
+
+// ExpireConditions removes the conditions whose end time has passed.
+func ExpireConditions() ([]model.Condition, error) {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	expired, err := deleteConditions(tx, "expires_at <= NOW()")
+	if err != nil {
+		return nil, err
+	}
+	return expired, tx.Commit(ctx)
+}
+
+// StartConditionExpiry periodically removes timed conditions and passes them
+// to onExpired, e.g. to notify the affected players.
+func StartConditionExpiry(interval time.Duration, onExpired func([]model.Condition)) {
+	if interval <= 0 {
+		log.Println("Condition expiry disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expired, err := ExpireConditions()
+			if err != nil {
+				log.Printf("Condition expiry failed: %v", err)
+				continue
+			}
+			if len(expired) > 0 && onExpired != nil {
+				onExpired(expired)
+			}
+		}
+	}()
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FogArea is a rectangle of cells to reveal or hide. All covers the whole map.
+type FogArea struct {
+	X      int  `json:"x"`
+	Y      int  `json:"y"`
+	Width  int  `json:"width"`
+	Height int  `json:"height"`
+	All    bool `json:"all"`
+}
+
+func cellRevealed(revealed []byte, width, x, y int) bool {
+	i := y*width + x
+	return i/8 < len(revealed) && revealed[i/8]&(1<<(i%8)) != 0
+}
+
+func setCell(revealed []byte, width, x, y int, value bool) {
+	i := y*width + x
+	if value {
+		revealed[i/8] |= 1 << (i % 8)
+	} else {
+		revealed[i/8] &^= 1 << (i % 8)
+	}
+}
+
+func fogSize(width, height int) int {
+	return (width*height + 7) / 8
+}
+
+// resizeFog keeps revealed cells at the same coordinates in a map of a new size.
+func resizeFog(revealed []byte, oldWidth, oldHeight, width, height int) []byte {
+	resized := make([]byte, fogSize(width, height))
+	for y := 0; y < min(oldHeight, height); y++ {
+		for x := 0; x < min(oldWidth, width); x++ {
+			if cellRevealed(revealed, oldWidth, x, y) {
+				setCell(resized, width, x, y, true)
+			}
+		}
+	}
+	return resized
+}
+
+// UpdateFog reveals or hides an area of the map.
+func UpdateFog(gameID, mapID string, area FogArea, reveal bool) (*model.BattleMap, error) {
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var width, height int
+	var revealed []byte
+	err = tx.QueryRow(context.Background(),
+		"SELECT width, height, revealed_cells FROM battle_maps WHERE game_id = $1 AND id = $2 FOR UPDATE",
+		gameID, mapID).Scan(&width, &height, &revealed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("map not found")
+		}
+		return nil, err
+	}
+
+	if len(revealed) != fogSize(width, height) {
+		revealed = resizeFog(revealed, width, height, width, height)
+	}
+
+	if area.All {
+		area = FogArea{Width: width, Height: height}
+	}
+	if area.Width <= 0 || area.Height <= 0 {
+		return nil, ErrTokenOutOfBounds
+	}
+
+	// Areas overlapping the edge are clipped to the map
+	for y := max(area.Y, 0); y < min(area.Y+area.Height, height); y++ {
+		for x := max(area.X, 0); x < min(area.X+area.Width, width); x++ {
+			setCell(revealed, width, x, y, reveal)
+		}
+	}
+
+	_, err = tx.Exec(context.Background(),
+		"UPDATE battle_maps SET revealed_cells = $1, updated_at = NOW() WHERE id = $2", revealed, mapID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetBattleMap(gameID, mapID)
+}
+
+// TokenVisibleTo reports whether a player may receive a token. The GM sees
+// everything and players always see their own tokens. Hidden tokens are only
+// shown to the players listed in VisibleTo, and with fog enabled a token must
+// cover at least one revealed cell.
+func TokenVisibleTo(m *model.BattleMap, t *model.MapToken, userID string, isGM bool) bool {
+	if isGM {
+		return true
+	}
+	if !m.IsActive {
+		return false
+	}
+	if t.OwnerID != nil && *t.OwnerID == userID {
+		return true
+	}
+	if t.Hidden && !slices.Contains(t.VisibleTo, userID) {
+		return false
+	}
+	if !m.FogEnabled {
+		return true
+	}
+
+	for y := t.Y; y < t.Y+t.Size && y < m.Height; y++ {
+		for x := t.X; x < t.X+t.Size && x < m.Width; x++ {
+			if cellRevealed(m.RevealedCells, m.Width, x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenForPlayer strips GM-only fields from a token sent to a player.
+func TokenForPlayer(t *model.MapToken) *model.MapToken {
+	copied := *t
+	copied.VisibleTo = nil
+	return &copied
+}
+
+// BattleMapForPlayer returns the part of a map a player may see, or nil if
+// the map is not shown to players.
+func BattleMapForPlayer(m *model.BattleMap, userID string) *model.BattleMap {
+	if !m.IsActive {
+		return nil
+	}
+
+	filtered := *m
+	filtered.Tokens = []model.MapToken{}
+	for i := range m.Tokens {
+		if TokenVisibleTo(m, &m.Tokens[i], userID, false) {
+			filtered.Tokens = append(filtered.Tokens, *TokenForPlayer(&m.Tokens[i]))
+		}
+	}
+	return &filtered
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidSession = errors.New("a title, a date and a positive duration are required")
+	ErrInvalidRSVP    = errors.New("rsvp must be yes, no or maybe")
+)
+
+type SessionInput struct {
+	Title           string    `json:"title"`
+	Agenda          string    `json:"agenda"`
+	ScheduledAt     time.Time `json:"scheduled_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+}
+
+func (in *SessionInput) validate() error {
+	if in.DurationMinutes == 0 {
+		in.DurationMinutes = 180
+	}
+	if in.Title == "" || in.ScheduledAt.IsZero() || in.DurationMinutes < 0 {
+		return ErrInvalidSession
+	}
+	return nil
+}
+
+const gameSessionColumns = `s.id, s.game_id, g.name, s.title, s.agenda, s.scheduled_at, s.duration_minutes, s.created_by, s.created_at, s.updated_at, s.cancelled_at,
+	s.started_at, s.ended_at, s.recap`
+
+func scanGameSession(row pgx.Row) (*model.GameSession, error) {
+	s := &model.GameSession{RSVPs: []model.SessionRSVP{}}
+	err := row.Scan(&s.ID, &s.GameID, &s.GameName, &s.Title, &s.Agenda, &s.ScheduledAt, &s.DurationMinutes, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt, &s.CancelledAt,
+		&s.StartedAt, &s.EndedAt, &s.Recap)
+	return s, err
+}
+
+// querySessions runs a session query and attaches the RSVPs.
+func querySessions(query string, args ...any) ([]model.GameSession, error) {
+	rows, err := database.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []model.GameSession{}
+	index := map[string]int{}
+	for rows.Next() {
+		s, err := scanGameSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[s.ID] = len(sessions)
+		sessions = append(sessions, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+
+	rsvpRows, err := database.DB.Query(context.Background(), `
+		SELECT r.session_id, r.user_id, COALESCE(u.name, ''), r.status, r.updated_at
+		FROM session_rsvps r
+		LEFT JOIN "user" u ON u.id = r.user_id
+		WHERE r.session_id = ANY($1::uuid[])
+		ORDER BY r.updated_at
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rsvpRows.Close()
+
+	for rsvpRows.Next() {
+		var sessionID string
+		var rsvp model.SessionRSVP
+		if err := rsvpRows.Scan(&sessionID, &rsvp.UserID, &rsvp.UserName, &rsvp.Status, &rsvp.UpdatedAt); err != nil {
+			return nil, err
+		}
+		i := index[sessionID]
+		sessions[i].RSVPs = append(sessions[i].RSVPs, rsvp)
+	}
+
+	return sessions, rsvpRows.Err()
+}
+
+func GetSession(gameID, sessionID string) (*model.GameSession, error) {
+	sessions, err := querySessions(`
+		SELECT `+gameSessionColumns+`
+		FROM game_sessions s
+		JOIN games g ON g.id = s.game_id
+		WHERE s.game_id = $1 AND s.id = $2
+	`, gameID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, errors.New("session not found")
+	}
+	return &sessions[0], nil
+}
+
+// GetGameSessions lists the sessions of a game, upcoming ones only unless includePast is set.
+func GetGameSessions(gameID string, includePast bool) ([]model.GameSession, error) {
+	return querySessions(`
+		SELECT `+gameSessionColumns+`
+		FROM game_sessions s
+		JOIN games g ON g.id = s.game_id
+		WHERE s.game_id = $1
+		AND ($2 OR s.scheduled_at + make_interval(mins => s.duration_minutes) >= NOW())
+		ORDER BY s.scheduled_at
+	`, gameID, includePast)
+}
+
+// GetUserSessions lists the sessions of every game the user plays or masters,
+// from a month ago onwards, for the calendar feed.
+func GetUserSessions(userID string) ([]model.GameSession, error) {
+	return querySessions(`
+		SELECT `+gameSessionColumns+`
+		FROM game_sessions s
+		JOIN games g ON g.id = s.game_id
+		WHERE (g.gm_id = $1 OR EXISTS (SELECT 1 FROM game_players gp WHERE gp.game_id = s.game_id AND gp.user_id = $1))
+		AND s.scheduled_at >= NOW() - INTERVAL '30 days'
+		ORDER BY s.scheduled_at
+	`, userID)
+}
+
+func CreateSession(gameID, createdBy string, input SessionInput) (*model.GameSession, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	var sessionID string
+	err := database.DB.QueryRow(context.Background(), `
+		INSERT INTO game_sessions (game_id, title, agenda, scheduled_at, duration_minutes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, gameID, input.Title, input.Agenda, input.ScheduledAt, input.DurationMinutes, createdBy).Scan(&sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetSession(gameID, sessionID)
+}
+
+func UpdateSession(gameID, sessionID string, input SessionInput) (*model.GameSession, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := database.DB.Exec(context.Background(), `
+		UPDATE game_sessions
+		SET title = $1, agenda = $2, scheduled_at = $3, duration_minutes = $4, updated_at = NOW()
+		WHERE game_id = $5 AND id = $6
+	`, input.Title, input.Agenda, input.ScheduledAt, input.DurationMinutes, gameID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("session not found")
+	}
+
+	return GetSession(gameID, sessionID)
+}
+
+// CancelSession marks a session as cancelled. It stays in calendar feeds so
+// subscribed calendars remove it.
+func CancelSession(gameID, sessionID string) (*model.GameSession, error) {
+	result, err := database.DB.Exec(context.Background(), `
+		UPDATE game_sessions SET cancelled_at = NOW(), updated_at = NOW()
+		WHERE game_id = $1 AND id = $2 AND cancelled_at IS NULL
+	`, gameID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("session not found")
+	}
+
+	return GetSession(gameID, sessionID)
+}
+
+func SetRSVP(gameID, sessionID, userID, status string) (*model.GameSession, error) {
+	if status != "yes" && status != "no" && status != "maybe" {
+		return nil, ErrInvalidRSVP
+	}
+
+	result, err := database.DB.Exec(context.Background(), `
+		INSERT INTO session_rsvps (session_id, user_id, status)
+		SELECT s.id, $3, $4 FROM game_sessions s WHERE s.game_id = $1 AND s.id = $2 AND s.cancelled_at IS NULL
+		ON CONFLICT (session_id, user_id) DO UPDATE SET status = EXCLUDED.status, updated_at = NOW()
+	`, gameID, sessionID, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("session not found")
+	}
+
+	return GetSession(gameID, sessionID)
+}
+
+// openSessionQuery selects the running session of game $1, if any.
+const openSessionQuery = `SELECT id FROM game_sessions WHERE game_id = $1 AND started_at IS NOT NULL AND ended_at IS NULL ORDER BY started_at DESC LIMIT 1`
+
+// StartSession opens the session log when the game goes ongoing. It resumes an
+// already running session, else starts the scheduled session closest to now
+// (within 12 hours), else creates an unscheduled one.
+func StartSession(gameID, gmID string) (*model.GameSession, error) {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize concurrent state toggles of the same game
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM games WHERE id = $1 FOR UPDATE`, gameID); err != nil {
+		return nil, err
+	}
+
+	var sessionID string
+	err = tx.QueryRow(ctx, openSessionQuery, gameID).Scan(&sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `
+			UPDATE game_sessions SET started_at = NOW(), updated_at = NOW()
+			WHERE id = (
+				SELECT id FROM game_sessions
+				WHERE game_id = $1 AND started_at IS NULL AND cancelled_at IS NULL
+				AND scheduled_at BETWEEN NOW() - INTERVAL '12 hours' AND NOW() + INTERVAL '12 hours'
+				ORDER BY ABS(EXTRACT(EPOCH FROM scheduled_at - NOW()))
+				LIMIT 1
+			)
+			RETURNING id
+		`, gameID).Scan(&sessionID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		now := time.Now()
+		err = tx.QueryRow(ctx, `
+			INSERT INTO game_sessions (game_id, title, scheduled_at, created_by, started_at)
+			VALUES ($1, $2, $3, $4, $3)
+			RETURNING id
+		`, gameID, "Session of "+now.UTC().Format("Jan 2, 2006"), now, gmID).Scan(&sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetSession(gameID, sessionID)
+}
+
+// EndSession closes the running session when the game is paused. It returns
+// nil when no session was running.
+func EndSession(gameID string) (*model.GameSession, error) {
+	var sessionID string
+	err := database.DB.QueryRow(context.Background(), `
+		UPDATE game_sessions SET ended_at = NOW(), updated_at = NOW()
+		WHERE id = (`+openSessionQuery+`)
+		RETURNING id
+	`, gameID).Scan(&sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetSession(gameID, sessionID)
+}
+
+func UpdateSessionRecap(gameID, sessionID, recap string) (*model.GameSession, error) {
+	result, err := database.DB.Exec(context.Background(), `
+		UPDATE game_sessions SET recap = $1, updated_at = NOW()
+		WHERE game_id = $2 AND id = $3
+	`, recap, gameID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("session not found")
+	}
+
+	return GetSession(gameID, sessionID)
+}
+
+// GetSessionMessages returns the chat log of a session as seen by userID:
+// private messages only appear to their sender and target.
+func GetSessionMessages(gameID, sessionID, userID string) ([]model.ChatMessage, error) {
+	rows, err := database.DB.Query(context.Background(),
+		`SELECT id, game_id, sender_id, sender_name, content, type, target_id, created_at, is_bot, session_id
+		FROM messages
+		WHERE game_id = $1 AND session_id = $2
+		AND (type != 'CHAT_PRIVATE' OR sender_id = $3 OR target_id = $3)
+		ORDER BY created_at ASC`,
+		gameID, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []model.ChatMessage{}
+	for rows.Next() {
+		var msg model.ChatMessage
+		err := rows.Scan(&msg.ID, &msg.GameID, &msg.SenderID, &msg.SenderName, &msg.Content, &msg.Type, &msg.TargetID, &msg.CreatedAt, &msg.IsBot, &msg.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"questhub/config"
+	"questhub/database"
+	"questhub/imaging"
+	model "questhub/models/database"
+	"questhub/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrHandoutType = errors.New("handouts must be an image, a PDF or a text file")
+
+func generateHandoutKey() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Non-image handout types and the extension they are stored with
+var handoutDocumentTypes = map[string]string{
+	"application/pdf":           ".pdf",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+const handoutColumns = `id, game_id, title, description, storage_key, file_name, content_type, size, revealed_to_all, created_by, created_at, revealed_at`
+
+func scanHandout(row pgx.Row) (*model.Handout, error) {
+	h := &model.Handout{}
+	err := row.Scan(&h.ID, &h.GameID, &h.Title, &h.Description, &h.StorageKey, &h.FileName, &h.ContentType, &h.Size, &h.RevealedToAll, &h.CreatedBy, &h.CreatedAt, &h.RevealedAt)
+	if err != nil {
+		return nil, err
+	}
+	h.URL = fmt.Sprintf("/table/%s/handouts/%s/file", h.GameID, h.ID)
+	return h, nil
+}
+
+// CreateHandout stores a hidden handout. Images are re-encoded like other uploads.
+func CreateHandout(gameID, createdBy, title, description, fileName string, data []byte) (*model.Handout, error) {
+	if len(data) > imaging.MaxUploadSize {
+		return nil, imaging.ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, isDocument := handoutDocumentTypes[contentType]
+	if !isDocument {
+		variants, err := imaging.Process(data, imaging.KindImage)
+		if err != nil {
+			if errors.Is(err, imaging.ErrNotImage) {
+				return nil, ErrHandoutType
+			}
+			return nil, err
+		}
+		for _, variant := range variants {
+			if variant.Name == imaging.OriginalVariant {
+				data, contentType, ext = variant.Data, variant.ContentType, variant.Ext
+			}
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUploadQuota(tx, createdBy); err != nil {
+		return nil, err
+	}
+	if config.UploadQuota > 0 {
+		used, err := usedStorage(tx, createdBy)
+		if err != nil {
+			return nil, err
+		}
+		if used+int64(len(data)) > config.UploadQuota {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	// Keep the name given by the GM, with the extension of what we actually store
+	fileName = strings.TrimSuffix(path.Base(fileName), path.Ext(fileName)) + ext
+	// Random so that a leaked or guessed name does not lead to other handouts
+	name, err := generateHandoutKey()
+	if err != nil {
+		return nil, err
+	}
+	key := name + ext
+	if err := storage.Private.Put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRow(ctx, `
+		INSERT INTO handouts (game_id, title, description, storage_key, file_name, content_type, size, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+handoutColumns,
+		gameID, title, description, key, fileName, contentType, len(data), createdBy)
+	handout, err := scanHandout(row)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		_ = storage.Private.Delete(ctx, key)
+		return nil, err
+	}
+	handout.RevealedTo = []string{}
+	return handout, nil
+}
+
+func GetHandout(gameID, handoutID string) (*model.Handout, error) {
+	row := database.DB.QueryRow(context.Background(),
+		"SELECT "+handoutColumns+" FROM handouts WHERE game_id = $1 AND id = $2", gameID, handoutID)
+	handout, err := scanHandout(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("handout not found")
+		}
+		return nil, err
+	}
+	return handout, nil
+}
+
+// GetGameHandouts returns every handout of the game with the players it was revealed to.
+func GetGameHandouts(gameID string) ([]model.Handout, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT `+handoutColumns+`,
+		       COALESCE((SELECT array_agg(r.user_id) FROM handout_reveals r WHERE r.handout_id = h.id), '{}')
+		FROM handouts h
+		WHERE game_id = $1
+		ORDER BY created_at DESC
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	handouts := []model.Handout{}
+	for rows.Next() {
+		var h model.Handout
+		if err := rows.Scan(&h.ID, &h.GameID, &h.Title, &h.Description, &h.StorageKey, &h.FileName, &h.ContentType, &h.Size, &h.RevealedToAll, &h.CreatedBy, &h.CreatedAt, &h.RevealedAt, &h.RevealedTo); err != nil {
+			return nil, err
+		}
+		h.URL = fmt.Sprintf("/table/%s/handouts/%s/file", h.GameID, h.ID)
+		handouts = append(handouts, h)
+	}
+	return handouts, rows.Err()
+}
+
+// GetPlayerHandouts returns the handouts revealed to a player.
+func GetPlayerHandouts(gameID, userID string) ([]model.Handout, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT `+handoutColumns+`
+		FROM handouts h
+		WHERE game_id = $1
+		AND (revealed_to_all OR EXISTS (SELECT 1 FROM handout_reveals r WHERE r.handout_id = h.id AND r.user_id = $2))
+		ORDER BY revealed_at DESC
+	`, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	handouts := []model.Handout{}
+	for rows.Next() {
+		handout, err := scanHandout(rows)
+		if err != nil {
+			return nil, err
+		}
+		handouts = append(handouts, *handout)
+	}
+	return handouts, rows.Err()
+}
+
+// CanViewHandout reports whether the handout was revealed to the user.
+func CanViewHandout(handoutID, userID string) (bool, error) {
+	var allowed bool
+	err := database.DB.QueryRow(context.Background(), `
+		SELECT revealed_to_all OR EXISTS (SELECT 1 FROM handout_reveals WHERE handout_id = $1 AND user_id = $2)
+		FROM handouts WHERE id = $1
+	`, handoutID, userID).Scan(&allowed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return allowed, err
+}
+
+// RevealHandout shares a handout with every player when userIDs is empty,
+// otherwise with the given players only. Reveals add up. revealedTo lists the
+// players who newly received it, ignoring users who are not players.
+func RevealHandout(gameID, handoutID string, userIDs []string) (handout *model.Handout, revealedTo []string, err error) {
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var tag string
+	if len(userIDs) == 0 {
+		err = tx.QueryRow(context.Background(), `
+			UPDATE handouts SET revealed_to_all = TRUE, revealed_at = COALESCE(revealed_at, NOW())
+			WHERE game_id = $1 AND id = $2
+			RETURNING id
+		`, gameID, handoutID).Scan(&tag)
+	} else {
+		err = tx.QueryRow(context.Background(), `
+			UPDATE handouts SET revealed_at = COALESCE(revealed_at, NOW())
+			WHERE game_id = $1 AND id = $2
+			RETURNING id
+		`, gameID, handoutID).Scan(&tag)
+		if err == nil {
+			// Only players of the game can receive it
+			var rows pgx.Rows
+			rows, err = tx.Query(context.Background(), `
+				INSERT INTO handout_reveals (handout_id, user_id)
+				SELECT $1, gp.user_id FROM game_players gp
+				WHERE gp.game_id = $2 AND gp.user_id = ANY($3)
+				ON CONFLICT DO NOTHING
+				RETURNING user_id
+			`, handoutID, gameID, userIDs)
+			if err == nil {
+				revealedTo, err = pgx.CollectRows(rows, pgx.RowTo[string])
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, errors.New("handout not found")
+		}
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, nil, err
+	}
+
+	handout, err = GetHandout(gameID, handoutID)
+	return handout, revealedTo, err
+}
+
+// HideHandout withdraws a handout from every player.
+func HideHandout(gameID, handoutID string) error {
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(), `
+		UPDATE handouts SET revealed_to_all = FALSE, revealed_at = NULL
+		WHERE game_id = $1 AND id = $2
+	`, gameID, handoutID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("handout not found")
+	}
+
+	if _, err := tx.Exec(context.Background(), "DELETE FROM handout_reveals WHERE handout_id = $1", handoutID); err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
+func DeleteHandout(gameID, handoutID string) error {
+	var key string
+	err := database.DB.QueryRow(context.Background(),
+		"DELETE FROM handouts WHERE game_id = $1 AND id = $2 RETURNING storage_key", gameID, handoutID).Scan(&key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("handout not found")
+		}
+		return err
+	}
+
+	if err := storage.Private.Delete(context.Background(), key); err != nil {
+		fmt.Printf("Failed to delete handout file %s: %v\n", key, err)
+	}
+	return nil
+}
+
+// OpenHandoutFile returns the content of the handout file.
+func OpenHandoutFile(handout *model.Handout) (io.ReadCloser, error) {
+	return storage.Private.Get(context.Background(), handout.StorageKey)
+}
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidNote   = errors.New("a title and a visibility among private, gm and party are required")
+	ErrUnknownReader = errors.New("notes can only be shared with members of this game")
+	ErrNotePrivate   = errors.New("this note is private")
+)
+
+// Who can read a note besides its author
+const (
+	NotePrivate = "private" // Only the author, e.g. GM secrets
+	NoteGM      = "gm"      // The author and the GM
+	NoteParty   = "party"   // Every member of the game
+)
+
+// Title of the note created by the character notes endpoints
+const defaultNoteTitle = "Notes"
+
+// noteVisibilitySQL matches the notes readable by user $2, who is the GM when $3 is true.
+const noteVisibilitySQL = `(n.user_id = $2 OR n.visibility = 'party' OR (n.visibility = 'gm' AND $3)
+	OR EXISTS (SELECT 1 FROM note_shares s WHERE s.note_id = n.id AND s.user_id = $2))`
+
+type NoteInput struct {
+	Title      string   `json:"title"`
+	Folder     string   `json:"folder"`
+	Tags       []string `json:"tags"`
+	Content    string   `json:"content"`
+	Visibility string   `json:"visibility"`
+	SharedWith []string `json:"shared_with"`
+}
+
+func (in *NoteInput) validate() error {
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		return ErrInvalidNote
+	}
+	if in.Visibility == "" {
+		in.Visibility = NotePrivate
+	}
+	switch in.Visibility {
+	case NotePrivate, NoteGM, NoteParty:
+	default:
+		return ErrInvalidNote
+	}
+
+	// "/NPCs//Villains/" is stored as "NPCs/Villains"
+	parts := []string{}
+	for _, part := range strings.Split(in.Folder, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	in.Folder = strings.Join(parts, "/")
+
+	tags := []string{}
+	for _, tag := range in.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	in.Tags = uniqueStrings(tags)
+	if in.SharedWith == nil {
+		in.SharedWith = []string{}
+	}
+	in.SharedWith = uniqueStrings(in.SharedWith)
+	return nil
+}
+
+// CanReadNote tells whether a member of the game can read the note.
+func CanReadNote(n *model.Note, userID string, isGM bool) bool {
+	if n.UserID == userID || n.Visibility == NoteParty || (n.Visibility == NoteGM && isGM) {
+		return true
+	}
+	for _, id := range n.SharedWith {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+const noteColumns = `
+	n.id, n.game_id, n.user_id, COALESCE(u.name, ''), n.title, n.folder, n.tags, n.content, n.visibility,
+	COALESCE((SELECT array_agg(s.user_id ORDER BY s.user_id) FROM note_shares s WHERE s.note_id = n.id), '{}'),
+	n.created_at, n.updated_at
+`
+
+func scanNote(row pgx.Row) (*model.Note, error) {
+	n := &model.Note{}
+	err := row.Scan(&n.ID, &n.GameID, &n.UserID, &n.AuthorName, &n.Title, &n.Folder, &n.Tags, &n.Content, &n.Visibility,
+		&n.SharedWith, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+type NoteFilter struct {
+	Folder string // Notes in this folder and its subfolders
+	Tag    string
+}
+
+// GetVisibleNotes lists the notes of a game the user can read.
+func GetVisibleNotes(gameID, userID string, isGM bool, filter NoteFilter) ([]model.Note, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT `+noteColumns+`
+		FROM notes n
+		LEFT JOIN "user" u ON u.id = n.user_id
+		WHERE n.game_id = $1 AND `+noteVisibilitySQL+`
+		  AND ($4 = '' OR n.folder = $4 OR n.folder LIKE $4 || '/%')
+		  AND ($5 = '' OR $5 = ANY(n.tags))
+		ORDER BY n.folder, n.title, n.created_at
+	`, gameID, userID, isGM, strings.Trim(filter.Folder, "/"), strings.ToLower(filter.Tag))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.Note{}
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *n)
+	}
+	return notes, rows.Err()
+}
+
+func GetNote(gameID, noteID string) (*model.Note, error) {
+	n, err := scanNote(database.DB.QueryRow(context.Background(), `
+		SELECT `+noteColumns+`
+		FROM notes n
+		LEFT JOIN "user" u ON u.id = n.user_id
+		WHERE n.game_id = $1 AND n.id = $2
+	`, gameID, noteID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("note not found")
+		}
+		return nil, err
+	}
+	return n, nil
+}
+
+// saveNoteShares replaces the members a note is shared with. They must be
+// players or the GM of the game; sharing with the author is ignored.
+func saveNoteShares(tx pgx.Tx, gameID, noteID, authorID string, userIDs []string) error {
+	ctx := context.Background()
+
+	readers := []string{}
+	for _, id := range userIDs {
+		if id != authorID {
+			readers = append(readers, id)
+		}
+	}
+
+	var members int
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM unnest($2::text[]) AS r(user_id)
+		WHERE r.user_id = (SELECT gm_id FROM games WHERE id = $1)
+		   OR EXISTS (SELECT 1 FROM game_players gp WHERE gp.game_id = $1 AND gp.user_id = r.user_id)
+	`, gameID, readers).Scan(&members)
+	if err != nil {
+		return err
+	}
+	if members != len(readers) {
+		return ErrUnknownReader
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM note_shares WHERE note_id = $1", noteID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO note_shares (note_id, user_id) SELECT $1, unnest($2::text[])", noteID, readers)
+	return err
+}
+
+func CreateNote(gameID, userID string, input NoteInput) (*model.Note, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var noteID string
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO notes (game_id, user_id, title, folder, tags, content, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, gameID, userID, input.Title, input.Folder, input.Tags, input.Content, input.Visibility).Scan(&noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveNoteShares(tx, gameID, noteID, userID, input.SharedWith); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetNote(gameID, noteID)
+}
+
+func UpdateNote(gameID, noteID string, input NoteInput) (*model.Note, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var authorID string
+	err = tx.QueryRow(context.Background(), `
+		UPDATE notes
+		SET title = $1, folder = $2, tags = $3, content = $4, visibility = $5, updated_at = NOW()
+		WHERE game_id = $6 AND id = $7
+		RETURNING user_id
+	`, input.Title, input.Folder, input.Tags, input.Content, input.Visibility, gameID, noteID).Scan(&authorID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("note not found")
+		}
+		return nil, err
+	}
+
+	if err := saveNoteShares(tx, gameID, noteID, authorID, input.SharedWith); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetNote(gameID, noteID)
+}
+
+func DeleteNote(gameID, noteID string) error {
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM notes WHERE game_id = $1 AND id = $2", gameID, noteID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("note not found")
+	}
+	return nil
+}
+
+// GetNotes returns the content of the user's first note in the game, which
+// the character notes endpoints read and write. A private note is only
+// returned to its author.
+func GetNotes(gameID, userID string, includePrivate bool) (string, error) {
+	var content, visibility string
+	err := database.DB.QueryRow(context.Background(), `
+		SELECT content, visibility FROM notes
+		WHERE game_id = $1 AND user_id = $2
+		ORDER BY created_at, id
+		LIMIT 1
+	`, gameID, userID).Scan(&content, &visibility)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil // No notes found, return empty string
+		}
+		return "", err
+	}
+	if visibility == NotePrivate && !includePrivate {
+		return "", ErrNotePrivate
+	}
+	return content, nil
+}
+
+// UpdateNotes writes the content of the user's first note in the game,
+// creating it readable by the GM when the user has none.
+func UpdateNotes(gameID, userID, content string, includePrivate bool) (*model.Note, error) {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var noteID, visibility string
+	err = tx.QueryRow(ctx, `
+		SELECT id, visibility FROM notes
+		WHERE game_id = $1 AND user_id = $2
+		ORDER BY created_at, id
+		LIMIT 1
+		FOR UPDATE
+	`, gameID, userID).Scan(&noteID, &visibility)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = tx.QueryRow(ctx, `
+			INSERT INTO notes (game_id, user_id, title, content, visibility)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, gameID, userID, defaultNoteTitle, content, NoteGM).Scan(&noteID)
+	case err != nil:
+	case visibility == NotePrivate && !includePrivate:
+		return nil, ErrNotePrivate
+	default:
+		_, err = tx.Exec(ctx, "UPDATE notes SET content = $1, updated_at = NOW() WHERE id = $2", content, noteID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetNote(gameID, noteID)
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"questhub/database"
+	model "questhub/models/database"
+	"questhub/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidQuest       = errors.New("a title is required and rewards cannot be negative")
+	ErrInvalidQuestStatus = errors.New("status must be available, active, completed or failed")
+	ErrInvalidItems       = errors.New("items must be a list of objects with a name")
+	ErrUnknownCharacter   = errors.New("character is not part of this game")
+)
+
+type QuestObjectiveInput struct {
+	ID          *string `json:"id"` // Set to keep an existing objective
+	Description string  `json:"description"`
+	Completed   bool    `json:"completed"`
+}
+
+type QuestInput struct {
+	Title        string                `json:"title"`
+	Description  string                `json:"description"`
+	GiverID      *string               `json:"giver_id"`
+	Hidden       bool                  `json:"hidden"`
+	RewardXP     int                   `json:"reward_xp"`
+	RewardMoney  int                   `json:"reward_money"`
+	RewardItems  json.RawMessage       `json:"reward_items"`
+	Objectives   []QuestObjectiveInput `json:"objectives"`
+	CharacterIDs []string              `json:"character_ids"`
+}
+
+func (in *QuestInput) validate() error {
+	if in.Title == "" || in.RewardXP < 0 || in.RewardMoney < 0 {
+		return ErrInvalidQuest
+	}
+	items, err := normalizeItems(in.RewardItems)
+	if err != nil {
+		return err
+	}
+	in.RewardItems = items
+	if in.GiverID != nil && *in.GiverID == "" {
+		in.GiverID = nil
+	}
+	// Duplicates would not match the assigned count
+	in.CharacterIDs = uniqueStrings(in.CharacterIDs)
+	return nil
+}
+
+// normalizeItems checks a list of inventory items and stores image URLs as upload keys.
+func normalizeItems(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("[]"), nil
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, ErrInvalidItems
+	}
+	for _, item := range items {
+		if name, _ := item["name"].(string); name == "" {
+			return nil, ErrInvalidItems
+		}
+		if url, ok := item["image_url"].(string); ok {
+			item["image_url"] = storage.KeyFromURL(url)
+		}
+	}
+	return json.Marshal(items)
+}
+
+func validQuestStatus(status string) bool {
+	switch status {
+	case "available", "active", "completed", "failed":
+		return true
+	}
+	return false
+}
+
+const questColumns = `q.id, q.game_id, q.title, q.description, q.giver_id, c.name, q.status, q.hidden,
+	q.reward_xp, q.reward_money, q.reward_items, q.rewarded_at, q.created_at, q.updated_at`
+
+const questFrom = `
+	FROM quests q
+	LEFT JOIN characters c ON c.id = q.giver_id
+`
+
+func scanQuest(row pgx.Row) (*model.Quest, error) {
+	q := &model.Quest{Objectives: []model.QuestObjective{}, CharacterIDs: []string{}}
+	err := row.Scan(&q.ID, &q.GameID, &q.Title, &q.Description, &q.GiverID, &q.GiverName, &q.Status, &q.Hidden,
+		&q.RewardXP, &q.RewardMoney, &q.RewardItems, &q.RewardedAt, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	q.RewardItems = resolveInventoryURLs(q.RewardItems)
+	return q, nil
+}
+
+// queryQuests runs a quest query and attaches objectives and assigned characters.
+func queryQuests(query string, args ...any) ([]model.Quest, error) {
+	rows, err := database.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quests := []model.Quest{}
+	index := map[string]int{}
+	for rows.Next() {
+		q, err := scanQuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[q.ID] = len(quests)
+		quests = append(quests, *q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(quests) == 0 {
+		return quests, nil
+	}
+
+	ids := make([]string, 0, len(quests))
+	for _, q := range quests {
+		ids = append(ids, q.ID)
+	}
+
+	objectiveRows, err := database.DB.Query(context.Background(), `
+		SELECT quest_id, id, description, completed
+		FROM quest_objectives
+		WHERE quest_id = ANY($1::uuid[])
+		ORDER BY position
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer objectiveRows.Close()
+
+	for objectiveRows.Next() {
+		var questID string
+		var o model.QuestObjective
+		if err := objectiveRows.Scan(&questID, &o.ID, &o.Description, &o.Completed); err != nil {
+			return nil, err
+		}
+		i := index[questID]
+		quests[i].Objectives = append(quests[i].Objectives, o)
+	}
+	if err := objectiveRows.Err(); err != nil {
+		return nil, err
+	}
+
+	characterRows, err := database.DB.Query(context.Background(),
+		"SELECT quest_id, character_id FROM quest_characters WHERE quest_id = ANY($1::uuid[])", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer characterRows.Close()
+
+	for characterRows.Next() {
+		var questID, characterID string
+		if err := characterRows.Scan(&questID, &characterID); err != nil {
+			return nil, err
+		}
+		i := index[questID]
+		quests[i].CharacterIDs = append(quests[i].CharacterIDs, characterID)
+	}
+	return quests, characterRows.Err()
+}
+
+// GetQuests lists the quests of a game. Players only see revealed quests.
+func GetQuests(gameID string, visibleOnly bool) ([]model.Quest, error) {
+	return queryQuests(`SELECT `+questColumns+questFrom+`
+		WHERE q.game_id = $1 AND (NOT $2 OR NOT q.hidden)
+		ORDER BY q.created_at
+	`, gameID, visibleOnly)
+}
+
+func GetQuest(gameID, questID string) (*model.Quest, error) {
+	quests, err := queryQuests(`SELECT `+questColumns+questFrom+` WHERE q.game_id = $1 AND q.id = $2`, gameID, questID)
+	if err != nil {
+		return nil, err
+	}
+	if len(quests) == 0 {
+		return nil, errors.New("quest not found")
+	}
+	return &quests[0], nil
+}
+
+// saveQuestLinks checks the giver and replaces the objectives and assigned
+// characters of a quest. Objectives sent with their id keep it.
+func saveQuestLinks(tx pgx.Tx, gameID, questID string, input QuestInput) error {
+	ctx := context.Background()
+
+	if input.GiverID != nil {
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM game_characters WHERE game_id = $1 AND character_id = $2)",
+			gameID, *input.GiverID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUnknownCharacter
+		}
+	}
+
+	kept := []string{}
+	for _, o := range input.Objectives {
+		if o.ID != nil {
+			kept = append(kept, *o.ID)
+		}
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM quest_objectives WHERE quest_id = $1 AND NOT (id = ANY($2::uuid[]))", questID, kept); err != nil {
+		return err
+	}
+	for position, o := range input.Objectives {
+		if o.Description == "" {
+			return ErrInvalidQuest
+		}
+		if o.ID != nil {
+			result, err := tx.Exec(ctx,
+				"UPDATE quest_objectives SET description = $1, completed = $2, position = $3 WHERE quest_id = $4 AND id = $5",
+				o.Description, o.Completed, position, questID, *o.ID)
+			if err != nil {
+				return err
+			}
+			if result.RowsAffected() > 0 {
+				continue
+			}
+		}
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO quest_objectives (quest_id, description, completed, position) VALUES ($1, $2, $3, $4)",
+			questID, o.Description, o.Completed, position); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM quest_characters WHERE quest_id = $1", questID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(ctx, `
+		INSERT INTO quest_characters (quest_id, character_id)
+		SELECT $1, character_id FROM game_characters WHERE game_id = $2 AND character_id = ANY($3::uuid[])
+	`, questID, gameID, input.CharacterIDs)
+	if err != nil {
+		return err
+	}
+	if int(result.RowsAffected()) != len(input.CharacterIDs) {
+		return ErrUnknownCharacter
+	}
+	return nil
+}
+
+// CreateQuest adds a quest. Reward images must be uploads of userID.
+func CreateQuest(gameID, userID string, input QuestInput) (*model.Quest, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	if err := checkItemImages(input.RewardItems, userID, nil); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var questID string
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO quests (game_id, title, description, giver_id, hidden, reward_xp, reward_money, reward_items)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, gameID, input.Title, input.Description, input.GiverID, input.Hidden, input.RewardXP, input.RewardMoney, input.RewardItems).Scan(&questID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveQuestLinks(tx, gameID, questID, input); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetQuest(gameID, questID)
+}
+
+// UpdateQuest changes a quest. New reward images must be uploads of userID.
+func UpdateQuest(gameID, questID, userID string, input QuestInput) (*model.Quest, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	previous, err := GetQuest(gameID, questID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkItemImages(input.RewardItems, userID, KeptUploads(nil, previous.RewardItems)); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(), `
+		UPDATE quests
+		SET title = $1, description = $2, giver_id = $3, hidden = $4, reward_xp = $5, reward_money = $6, reward_items = $7, updated_at = NOW()
+		WHERE game_id = $8 AND id = $9
+	`, input.Title, input.Description, input.GiverID, input.Hidden, input.RewardXP, input.RewardMoney, input.RewardItems, gameID, questID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("quest not found")
+	}
+
+	if err := saveQuestLinks(tx, gameID, questID, input); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetQuest(gameID, questID)
+}
+
+// SetQuestStatus changes the status of a quest. The first time a quest is
+// completed its rewards go to the assigned characters: experience and money
+// are added and the items appended to their inventory. It returns the ids of
+// the rewarded characters.
+func SetQuestStatus(gameID, questID, status string) (*model.Quest, []string, error) {
+	if !validQuestStatus(status) {
+		return nil, nil, ErrInvalidQuestStatus
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var rewardXP, rewardMoney int
+	var rewardItems json.RawMessage
+	var rewarded bool
+	err = tx.QueryRow(ctx, `
+		SELECT reward_xp, reward_money, reward_items, rewarded_at IS NOT NULL
+		FROM quests WHERE game_id = $1 AND id = $2
+		FOR UPDATE
+	`, gameID, questID).Scan(&rewardXP, &rewardMoney, &rewardItems, &rewarded)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, errors.New("quest not found")
+		}
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE quests SET status = $1, updated_at = NOW() WHERE id = $2", status, questID); err != nil {
+		return nil, nil, err
+	}
+
+	rewardedIDs := []string{}
+	if status == "completed" && !rewarded {
+		rows, err := tx.Query(ctx, `
+			UPDATE characters c
+			SET experience = c.experience + $2,
+			    money = c.money + $3,
+			    inventory = CASE WHEN jsonb_typeof(c.inventory) = 'array' THEN c.inventory ELSE '[]'::jsonb END || $4::jsonb
+			FROM quest_characters qc
+			WHERE qc.quest_id = $1 AND qc.character_id = c.id
+			RETURNING c.id
+		`, questID, rewardXP, rewardMoney, rewardItems)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			rewardedIDs = append(rewardedIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE quests SET rewarded_at = NOW() WHERE id = $1", questID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	quest, err := GetQuest(gameID, questID)
+	return quest, rewardedIDs, err
+}
+
+func SetObjectiveCompleted(gameID, questID, objectiveID string, completed bool) (*model.Quest, error) {
+	result, err := database.DB.Exec(context.Background(), `
+		UPDATE quest_objectives o SET completed = $1
+		FROM quests q
+		WHERE q.id = o.quest_id AND q.game_id = $2 AND q.id = $3 AND o.id = $4
+	`, completed, gameID, questID, objectiveID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("objective not found")
+	}
+
+	if _, err := database.DB.Exec(context.Background(), "UPDATE quests SET updated_at = NOW() WHERE id = $1", questID); err != nil {
+		return nil, err
+	}
+	return GetQuest(gameID, questID)
+}
+
+func DeleteQuest(gameID, questID string) (*model.Quest, error) {
+	quest, err := GetQuest(gameID, questID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := database.DB.Exec(context.Background(), "DELETE FROM quests WHERE game_id = $1 AND id = $2", gameID, questID); err != nil {
+		return nil, err
+	}
+	return quest, nil
+}
@@ -0,0 +1,530 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidRandomTable = errors.New("a name and at least one entry with text, an item or a nested table are required")
+	ErrInvalidDice        = errors.New("dice must look like d100, 2d6 or 1d8+2")
+	ErrInvalidRange       = errors.New("entries of a dice table need a range within the dice results, without overlaps")
+	ErrInvalidTableRef    = errors.New("nested tables must belong to this game or be shared")
+	ErrRandomTableDepth   = errors.New("nested tables go too deep")
+	ErrRandomTableCycle   = errors.New("nested tables cannot lead back to the table itself")
+)
+
+// Nested references are followed this many times at most, which also stops cycles
+const maxRollDepth = 5
+
+var diceExpression = regexp.MustCompile(`^(\d*)d(\d+)([+-]\d+)?$`)
+
+type diceRoll struct {
+	count, sides, modifier int
+}
+
+func parseDice(expr string) (diceRoll, error) {
+	m := diceExpression.FindStringSubmatch(strings.ToLower(strings.ReplaceAll(expr, " ", "")))
+	if m == nil {
+		return diceRoll{}, ErrInvalidDice
+	}
+	d := diceRoll{count: 1}
+	if m[1] != "" {
+		d.count, _ = strconv.Atoi(m[1])
+	}
+	d.sides, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		d.modifier, _ = strconv.Atoi(m[3])
+	}
+	if d.count < 1 || d.count > 100 || d.sides < 1 || d.sides > 1000 {
+		return diceRoll{}, ErrInvalidDice
+	}
+	return d, nil
+}
+
+func (d diceRoll) min() int { return d.count + d.modifier }
+func (d diceRoll) max() int { return d.count*d.sides + d.modifier }
+
+func (d diceRoll) roll() int {
+	total := d.modifier
+	for i := 0; i < d.count; i++ {
+		total += rand.Intn(d.sides) + 1
+	}
+	return total
+}
+
+type RandomTableEntryInput struct {
+	Text     string          `json:"text"`
+	Weight   int             `json:"weight"`
+	RangeMin *int            `json:"range_min"`
+	RangeMax *int            `json:"range_max"`
+	TableRef *string         `json:"table_ref"`
+	Item     json.RawMessage `json:"item"`
+}
+
+type RandomTableInput struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Dice        string                  `json:"dice"`
+	Shared      bool                    `json:"shared"`
+	Entries     []RandomTableEntryInput `json:"entries"`
+}
+
+func (in *RandomTableInput) validate() error {
+	if in.Name == "" || len(in.Entries) == 0 {
+		return ErrInvalidRandomTable
+	}
+
+	var dice *diceRoll
+	if in.Dice != "" {
+		d, err := parseDice(in.Dice)
+		if err != nil {
+			return err
+		}
+		dice = &d
+	}
+
+	covered := map[int]bool{}
+	for i := range in.Entries {
+		e := &in.Entries[i]
+		if e.TableRef != nil && *e.TableRef == "" {
+			e.TableRef = nil
+		}
+		if len(e.Item) == 0 || string(e.Item) == "null" {
+			e.Item = nil
+		} else {
+			items, err := normalizeItems(json.RawMessage("[" + string(e.Item) + "]"))
+			if err != nil {
+				return err
+			}
+			e.Item = items[1 : len(items)-1]
+		}
+		if e.Text == "" && e.TableRef == nil && e.Item == nil {
+			return ErrInvalidRandomTable
+		}
+
+		if dice == nil {
+			if e.Weight == 0 {
+				e.Weight = 1
+			}
+			if e.Weight < 0 {
+				return ErrInvalidRandomTable
+			}
+			e.RangeMin, e.RangeMax = nil, nil
+			continue
+		}
+
+		if e.RangeMin == nil {
+			return ErrInvalidRange
+		}
+		if e.RangeMax == nil {
+			e.RangeMax = e.RangeMin
+		}
+		if *e.RangeMin > *e.RangeMax || *e.RangeMin < dice.min() || *e.RangeMax > dice.max() {
+			return ErrInvalidRange
+		}
+		for r := *e.RangeMin; r <= *e.RangeMax; r++ {
+			if covered[r] {
+				return ErrInvalidRange
+			}
+			covered[r] = true
+		}
+	}
+	return nil
+}
+
+// checkEntryImages makes sure the items of the entries show images userID
+// uploaded, or images kept from the table being changed.
+func checkEntryImages(entries []RandomTableEntryInput, userID string, kept map[string]bool) error {
+	for _, e := range entries {
+		if e.Item == nil {
+			continue
+		}
+		if err := checkItemImages(json.RawMessage("["+string(e.Item)+"]"), userID, kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const randomTableColumns = `id, game_id, name, description, dice, shared, created_by, created_at, updated_at`
+
+func scanRandomTable(row pgx.Row) (*model.RandomTable, error) {
+	t := &model.RandomTable{Entries: []model.RandomTableEntry{}}
+	err := row.Scan(&t.ID, &t.GameID, &t.Name, &t.Description, &t.Dice, &t.Shared, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// queryRandomTables runs a table query, with the entries when withEntries is set.
+func queryRandomTables(withEntries bool, query string, args ...any) ([]model.RandomTable, error) {
+	rows, err := database.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []model.RandomTable{}
+	index := map[string]int{}
+	for rows.Next() {
+		t, err := scanRandomTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[t.ID] = len(tables)
+		tables = append(tables, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !withEntries || len(tables) == 0 {
+		return tables, nil
+	}
+
+	ids := make([]string, 0, len(tables))
+	for _, t := range tables {
+		ids = append(ids, t.ID)
+	}
+
+	entryRows, err := database.DB.Query(context.Background(), `
+		SELECT table_id, id, text, weight, range_min, range_max, table_ref, item
+		FROM random_table_entries
+		WHERE table_id = ANY($1::uuid[])
+		ORDER BY position
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer entryRows.Close()
+
+	for entryRows.Next() {
+		var tableID string
+		var e model.RandomTableEntry
+		if err := entryRows.Scan(&tableID, &e.ID, &e.Text, &e.Weight, &e.RangeMin, &e.RangeMax, &e.TableRef, &e.Item); err != nil {
+			return nil, err
+		}
+		if e.Item != nil {
+			e.Item = resolveItemURL(e.Item)
+		}
+		i := index[tableID]
+		tables[i].Entries = append(tables[i].Entries, e)
+	}
+	return tables, entryRows.Err()
+}
+
+// resolveItemURL turns the image key of a single inventory item into a URL.
+func resolveItemURL(item json.RawMessage) json.RawMessage {
+	resolved := resolveInventoryURLs(json.RawMessage("[" + string(item) + "]"))
+	return resolved[1 : len(resolved)-1]
+}
+
+func GetRandomTables(gameID string) ([]model.RandomTable, error) {
+	return queryRandomTables(true, "SELECT "+randomTableColumns+" FROM random_tables WHERE game_id = $1 ORDER BY name", gameID)
+}
+
+// GetSharedRandomTables lists the tables GMs shared, without their entries.
+func GetSharedRandomTables() ([]model.RandomTable, error) {
+	return queryRandomTables(false, "SELECT "+randomTableColumns+" FROM random_tables WHERE shared ORDER BY name")
+}
+
+// GetRandomTable returns a table of the game, or a shared table of any game.
+func GetRandomTable(gameID, tableID string) (*model.RandomTable, error) {
+	tables, err := queryRandomTables(true,
+		"SELECT "+randomTableColumns+" FROM random_tables WHERE id = $2 AND (game_id = $1 OR shared)", gameID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("random table not found")
+	}
+	return &tables[0], nil
+}
+
+func saveRandomTableEntries(tx pgx.Tx, gameID, tableID string, entries []RandomTableEntryInput) error {
+	ctx := context.Background()
+	if _, err := tx.Exec(ctx, "DELETE FROM random_table_entries WHERE table_id = $1", tableID); err != nil {
+		return err
+	}
+
+	for position, e := range entries {
+		if e.TableRef != nil {
+			var usable bool
+			err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM random_tables WHERE id = $1 AND (game_id = $2 OR shared))",
+				*e.TableRef, gameID).Scan(&usable)
+			if err != nil {
+				return err
+			}
+			if !usable {
+				return ErrInvalidTableRef
+			}
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO random_table_entries (table_id, position, text, weight, range_min, range_max, table_ref, item)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, tableID, position, e.Text, e.Weight, e.RangeMin, e.RangeMax, e.TableRef, e.Item)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A table reaching itself through nested tables could never be rolled
+	var cycle bool
+	err := tx.QueryRow(ctx, `
+		WITH RECURSIVE reachable(id) AS (
+			SELECT table_ref FROM random_table_entries WHERE table_id = $1 AND table_ref IS NOT NULL
+			UNION
+			SELECT e.table_ref FROM random_table_entries e JOIN reachable r ON e.table_id = r.id WHERE e.table_ref IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM reachable WHERE id = $1)
+	`, tableID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrRandomTableCycle
+	}
+	return nil
+}
+
+func CreateRandomTable(gameID, createdBy string, input RandomTableInput) (*model.RandomTable, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	if err := checkEntryImages(input.Entries, createdBy, nil); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var tableID string
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO random_tables (game_id, name, description, dice, shared, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, gameID, input.Name, input.Description, input.Dice, input.Shared, createdBy).Scan(&tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveRandomTableEntries(tx, gameID, tableID, input.Entries); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetRandomTable(gameID, tableID)
+}
+
+func UpdateRandomTable(gameID, tableID, userID string, input RandomTableInput) (*model.RandomTable, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	previous, err := GetRandomTable(gameID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	var previousItems []json.RawMessage
+	for _, e := range previous.Entries {
+		if e.Item != nil {
+			previousItems = append(previousItems, json.RawMessage("["+string(e.Item)+"]"))
+		}
+	}
+	if err := checkEntryImages(input.Entries, userID, KeptUploads(nil, previousItems...)); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(), `
+		UPDATE random_tables SET name = $1, description = $2, dice = $3, shared = $4, updated_at = NOW()
+		WHERE game_id = $5 AND id = $6
+	`, input.Name, input.Description, input.Dice, input.Shared, gameID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.New("random table not found")
+	}
+
+	if err := saveRandomTableEntries(tx, gameID, tableID, input.Entries); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetRandomTable(gameID, tableID)
+}
+
+func DeleteRandomTable(gameID, tableID string) error {
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM random_tables WHERE game_id = $1 AND id = $2", gameID, tableID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("random table not found")
+	}
+	return nil
+}
+
+// ImportRandomTable copies a shared table into the game. Nested references
+// are kept, they keep working as long as the referenced tables stay shared.
+func ImportRandomTable(gameID, tableID, createdBy string) (*model.RandomTable, error) {
+	source, err := GetRandomTable(gameID, tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	input := RandomTableInput{
+		Name:        source.Name,
+		Description: source.Description,
+		Dice:        source.Dice,
+		Entries:     make([]RandomTableEntryInput, 0, len(source.Entries)),
+	}
+	for _, e := range source.Entries {
+		input.Entries = append(input.Entries, RandomTableEntryInput{
+			Text: e.Text, Weight: e.Weight, RangeMin: e.RangeMin, RangeMax: e.RangeMax, TableRef: e.TableRef, Item: e.Item,
+		})
+	}
+	return CreateRandomTable(gameID, createdBy, input)
+}
+
+// RollRandomTable rolls on a table and follows nested tables.
+func RollRandomTable(gameID, tableID string) (*model.RandomTableRoll, error) {
+	return rollRandomTable(gameID, tableID, 0)
+}
+
+func rollRandomTable(gameID, tableID string, depth int) (*model.RandomTableRoll, error) {
+	if depth >= maxRollDepth {
+		return nil, ErrRandomTableDepth
+	}
+
+	table, err := GetRandomTable(gameID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.RandomTableRoll{TableID: table.ID, TableName: table.Name}
+
+	if table.Dice != "" {
+		dice, err := parseDice(table.Dice)
+		if err != nil {
+			return nil, err
+		}
+		result.Roll = dice.roll()
+		for i, e := range table.Entries {
+			if e.RangeMin != nil && e.RangeMax != nil && *e.RangeMin <= result.Roll && result.Roll <= *e.RangeMax {
+				result.Entry = &table.Entries[i]
+				break
+			}
+		}
+	} else {
+		total := 0
+		for _, e := range table.Entries {
+			total += e.Weight
+		}
+		if total > 0 {
+			result.Roll = rand.Intn(total) + 1
+			n := result.Roll
+			for i, e := range table.Entries {
+				if n <= e.Weight {
+					result.Entry = &table.Entries[i]
+					break
+				}
+				n -= e.Weight
+			}
+		}
+	}
+
+	if result.Entry != nil && result.Entry.TableRef != nil {
+		result.Nested, err = rollRandomTable(gameID, *result.Entry.TableRef, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// RollItems collects the loot items of a roll and its nested rolls.
+func RollItems(roll *model.RandomTableRoll) []json.RawMessage {
+	items := []json.RawMessage{}
+	for r := roll; r != nil; r = r.Nested {
+		if r.Entry != nil && r.Entry.Item != nil {
+			items = append(items, r.Entry.Item)
+		}
+	}
+	return items
+}
+
+// DescribeRoll renders a roll as a chat line.
+func DescribeRoll(roll *model.RandomTableRoll) string {
+	parts := []string{}
+	for r := roll; r != nil; r = r.Nested {
+		outcome := "nothing"
+		if r.Entry != nil {
+			outcome = r.Entry.Text
+			if outcome == "" && r.Entry.Item != nil {
+				var item struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(r.Entry.Item, &item)
+				outcome = item.Name
+			}
+		}
+		if outcome == "" {
+			parts = append(parts, fmt.Sprintf("%s (%d)", r.TableName, r.Roll))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%d): %s", r.TableName, r.Roll, outcome))
+		}
+	}
+	return "🎲 " + strings.Join(parts, " → ")
+}
+
+// AddInventoryItems appends items to the inventory of a character of the game.
+func AddInventoryItems(gameID, characterID string, items []json.RawMessage) error {
+	if len(items) == 0 {
+		return nil
+	}
+	list, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	// Items are stored with upload keys, as sent by the client
+	list, err = normalizeItems(list)
+	if err != nil {
+		return err
+	}
+
+	result, err := database.DB.Exec(context.Background(), `
+		UPDATE characters c
+		SET inventory = CASE WHEN jsonb_typeof(c.inventory) = 'array' THEN c.inventory ELSE '[]'::jsonb END || $3::jsonb
+		FROM game_characters gc
+		WHERE gc.character_id = c.id AND gc.game_id = $1 AND c.id = $2
+	`, gameID, characterID, list)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("character not found")
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrEmptyStashMovement  = errors.New("nothing to move: give an amount of money or items")
+	ErrInsufficientFunds   = errors.New("not enough money")
+	ErrInvalidStashItem    = errors.New("item not found")
+	ErrStashWithdrawLocked = errors.New("the GM has not allowed players to withdraw from the stash")
+	ErrInvalidInventory    = errors.New("the character inventory is not a list of items")
+)
+
+// StashMovement describes a deposit or a withdrawal. Deposits pick items by
+// their index in the character inventory, withdrawals by their stash id.
+// Without a character the money and items appear or vanish, which only the GM may do.
+type StashMovement struct {
+	CharacterID string            `json:"character_id"`
+	Money       int               `json:"money"`
+	ItemIndexes []int             `json:"item_indexes"` // Deposit
+	ItemIDs     []string          `json:"item_ids"`     // Withdraw
+	Items       []json.RawMessage `json:"items"`        // GM deposit without a character
+}
+
+func generateItemID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func GetStash(gameID string) (*model.PartyStash, error) {
+	stash := &model.PartyStash{GameID: gameID, Items: json.RawMessage("[]")}
+	err := database.DB.QueryRow(context.Background(),
+		"SELECT money, items, free_withdraw, updated_at FROM party_stashes WHERE game_id = $1", gameID,
+	).Scan(&stash.Money, &stash.Items, &stash.FreeWithdraw, &stash.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	stash.Items = resolveInventoryURLs(stash.Items)
+	return stash, nil
+}
+
+func SetStashFreeWithdraw(gameID string, freeWithdraw bool) (*model.PartyStash, error) {
+	_, err := database.DB.Exec(context.Background(), `
+		INSERT INTO party_stashes (game_id, free_withdraw) VALUES ($1, $2)
+		ON CONFLICT (game_id) DO UPDATE SET free_withdraw = EXCLUDED.free_withdraw, updated_at = NOW()
+	`, gameID, freeWithdraw)
+	if err != nil {
+		return nil, err
+	}
+	return GetStash(gameID)
+}
+
+// lockStash creates the stash if needed and locks it for the transaction.
+func lockStash(tx pgx.Tx, gameID string) (money int, items []map[string]any, freeWithdraw bool, err error) {
+	ctx := context.Background()
+	if _, err = tx.Exec(ctx, "INSERT INTO party_stashes (game_id) VALUES ($1) ON CONFLICT (game_id) DO NOTHING", gameID); err != nil {
+		return
+	}
+	var raw json.RawMessage
+	err = tx.QueryRow(ctx, "SELECT money, items, free_withdraw FROM party_stashes WHERE game_id = $1 FOR UPDATE", gameID).
+		Scan(&money, &raw, &freeWithdraw)
+	if err != nil {
+		return
+	}
+	items = []map[string]any{}
+	err = json.Unmarshal(raw, &items)
+	return
+}
+
+// lockCharacter locks a character of the game and returns its name, money and inventory.
+func lockCharacter(tx pgx.Tx, gameID, characterID string) (name string, money int, inventory []map[string]any, err error) {
+	var raw json.RawMessage
+	err = tx.QueryRow(context.Background(), `
+		SELECT c.name, c.money, c.inventory
+		FROM characters c
+		JOIN game_characters gc ON gc.character_id = c.id
+		WHERE gc.game_id = $1 AND c.id = $2
+		FOR UPDATE OF c
+	`, gameID, characterID).Scan(&name, &money, &raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = errors.New("character not found")
+	}
+	if err != nil {
+		return
+	}
+	inventory = []map[string]any{}
+	if json.Unmarshal(raw, &inventory) != nil {
+		err = ErrInvalidInventory
+	}
+	return
+}
+
+func saveStashMovement(tx pgx.Tx, gameID, actorID, action string, characterID *string, characterName *string,
+	money int, moved []map[string]any, stashMoney int, stashItems []map[string]any) (*model.StashLedgerEntry, error) {
+	ctx := context.Background()
+
+	itemsJSON, err := json.Marshal(stashItems)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE party_stashes SET money = $1, items = $2, updated_at = NOW() WHERE game_id = $3",
+		stashMoney, itemsJSON, gameID); err != nil {
+		return nil, err
+	}
+
+	movedJSON, err := json.Marshal(moved)
+	if err != nil {
+		return nil, err
+	}
+	entry := &model.StashLedgerEntry{
+		GameID: gameID, ActorID: actorID, CharacterID: characterID, CharacterName: characterName,
+		Action: action, Money: money, Items: movedJSON,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO stash_ledger (game_id, actor_id, character_id, character_name, action, money, items)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, (SELECT COALESCE(name, '') FROM "user" WHERE id = $2)
+	`, gameID, actorID, characterID, characterName, action, money, movedJSON).Scan(&entry.ID, &entry.CreatedAt, &entry.ActorName)
+	if err != nil {
+		return nil, err
+	}
+	entry.Items = resolveInventoryURLs(entry.Items)
+	return entry, nil
+}
+
+// updateCharacterPurse saves the money of a character, and its inventory
+// when items moved (nil otherwise).
+func updateCharacterPurse(tx pgx.Tx, characterID string, money int, inventory []map[string]any) error {
+	if inventory == nil {
+		_, err := tx.Exec(context.Background(), "UPDATE characters SET money = $1 WHERE id = $2", money, characterID)
+		return err
+	}
+	inventoryJSON, err := json.Marshal(inventory)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(context.Background(), "UPDATE characters SET money = $1, inventory = $2 WHERE id = $3", money, inventoryJSON, characterID)
+	return err
+}
+
+// DepositToStash moves money and items from a character to the party stash,
+// or adds them out of nowhere when no character is given.
+func DepositToStash(gameID, actorID string, m StashMovement) (*model.PartyStash, *model.StashLedgerEntry, error) {
+	if m.Money < 0 || (m.Money == 0 && len(m.ItemIndexes) == 0 && len(m.Items) == 0) {
+		return nil, nil, ErrEmptyStashMovement
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	stashMoney, stashItems, _, err := lockStash(tx, gameID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	moved := []map[string]any{}
+	var characterID, characterName *string
+	if m.CharacterID != "" {
+		name, money, inventory, err := lockCharacter(tx, gameID, m.CharacterID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if money < m.Money {
+			return nil, nil, ErrInsufficientFunds
+		}
+
+		picked := map[int]bool{}
+		for _, i := range m.ItemIndexes {
+			if i < 0 || i >= len(inventory) || picked[i] {
+				return nil, nil, ErrInvalidStashItem
+			}
+			picked[i] = true
+		}
+		kept := []map[string]any{}
+		for i, item := range inventory {
+			if picked[i] {
+				moved = append(moved, item)
+			} else {
+				kept = append(kept, item)
+			}
+		}
+
+		if len(moved) == 0 {
+			kept = nil
+		}
+		if err := updateCharacterPurse(tx, m.CharacterID, money-m.Money, kept); err != nil {
+			return nil, nil, err
+		}
+		characterID, characterName = &m.CharacterID, &name
+	} else {
+		list, err := json.Marshal(m.Items)
+		if err != nil {
+			return nil, nil, err
+		}
+		if list, err = normalizeItems(list); err != nil {
+			return nil, nil, err
+		}
+		if err := checkItemImages(list, actorID, nil); err != nil {
+			return nil, nil, err
+		}
+		json.Unmarshal(list, &moved)
+	}
+
+	for _, item := range moved {
+		id, err := generateItemID()
+		if err != nil {
+			return nil, nil, err
+		}
+		stored := map[string]any{}
+		for k, v := range item {
+			stored[k] = v
+		}
+		stored["id"] = id
+		stashItems = append(stashItems, stored)
+	}
+
+	entry, err := saveStashMovement(tx, gameID, actorID, "deposit", characterID, characterName, m.Money, moved, stashMoney+m.Money, stashItems)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	stash, err := GetStash(gameID)
+	return stash, entry, err
+}
+
+// WithdrawFromStash moves money and items from the party stash to a
+// character, or removes them when no character is given. With
+// requireFreeWithdraw the GM must have allowed players to withdraw.
+func WithdrawFromStash(gameID, actorID string, m StashMovement, requireFreeWithdraw bool) (*model.PartyStash, *model.StashLedgerEntry, error) {
+	if m.Money < 0 || (m.Money == 0 && len(m.ItemIDs) == 0) {
+		return nil, nil, ErrEmptyStashMovement
+	}
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	stashMoney, stashItems, freeWithdraw, err := lockStash(tx, gameID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if requireFreeWithdraw && !freeWithdraw {
+		return nil, nil, ErrStashWithdrawLocked
+	}
+	if stashMoney < m.Money {
+		return nil, nil, ErrInsufficientFunds
+	}
+
+	picked := map[string]bool{}
+	for _, id := range m.ItemIDs {
+		picked[id] = true
+	}
+	moved := []map[string]any{}
+	kept := []map[string]any{}
+	for _, item := range stashItems {
+		if id, _ := item["id"].(string); picked[id] {
+			delete(item, "id")
+			moved = append(moved, item)
+			delete(picked, id)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	if len(picked) > 0 {
+		return nil, nil, ErrInvalidStashItem
+	}
+
+	var characterID, characterName *string
+	if m.CharacterID != "" {
+		name, money, inventory, err := lockCharacter(tx, gameID, m.CharacterID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(moved) > 0 {
+			inventory = append(inventory, moved...)
+		} else {
+			inventory = nil
+		}
+		if err := updateCharacterPurse(tx, m.CharacterID, money+m.Money, inventory); err != nil {
+			return nil, nil, err
+		}
+		characterID, characterName = &m.CharacterID, &name
+	}
+
+	entry, err := saveStashMovement(tx, gameID, actorID, "withdraw", characterID, characterName, m.Money, moved, stashMoney-m.Money, kept)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	stash, err := GetStash(gameID)
+	return stash, entry, err
+}
+
+// GetStashLedger lists the movements of the stash, most recent first.
+func GetStashLedger(gameID string, limit int) ([]model.StashLedgerEntry, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT l.id, l.game_id, l.actor_id, COALESCE(u.name, ''), l.character_id, l.character_name, l.action, l.money, l.items, l.created_at
+		FROM stash_ledger l
+		LEFT JOIN "user" u ON u.id = l.actor_id
+		WHERE l.game_id = $1
+		ORDER BY l.created_at DESC
+		LIMIT $2
+	`, gameID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.StashLedgerEntry{}
+	for rows.Next() {
+		var e model.StashLedgerEntry
+		if err := rows.Scan(&e.ID, &e.GameID, &e.ActorID, &e.ActorName, &e.CharacterID, &e.CharacterName, &e.Action, &e.Money, &e.Items, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Items = resolveInventoryURLs(e.Items)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -7,13 +7,14 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"questhub/database"
 	model "questhub/models/database"
+	"questhub/storage"
+
+	"github.com/jackc/pgx/v5"
 )
 
 func generateInviteCode() (string, error) {
@@ -51,6 +52,7 @@ func CreateTable(name, gmID, imageURL string) (*model.Game, error) {
 		return nil, err
 	}
 
+	game.ImageURL = storage.PublicURL(game.ImageURL)
 	return game, nil
 }
 
@@ -67,6 +69,7 @@ func GetTable(id string) (*model.Game, error) {
 	if err != nil {
 		return nil, err
 	}
+	game.ImageURL = storage.PublicURL(game.ImageURL)
 	return game, nil
 }
 
@@ -91,6 +94,7 @@ func GetGames(userID string) ([]model.Game, error) {
 		if err := rows.Scan(&game.ID, &game.Name, &game.GmID, &game.GmName, &game.InviteCode, &game.IsActive, &game.ImageURL, &game.State, &game.CreatedAt); err != nil {
 			return nil, err
 		}
+		game.ImageURL = storage.PublicURL(game.ImageURL)
 		games = append(games, game)
 	}
 
@@ -105,6 +109,15 @@ func JoinTable(inviteCode, userID string) (string, error) {
 		return "", err
 	}
 
+	// Banned users cannot send a new request while the ban is active
+	banned, err := IsBanned(gameID, userID)
+	if err != nil {
+		return gameID, err
+	}
+	if banned {
+		return gameID, ErrUserBanned
+	}
+
 	// Insert into game_invitations
 	query := `
 		INSERT INTO game_invitations (game_id, user_id)
@@ -144,6 +157,67 @@ func GetPendingInvitations(gameID string) ([]model.Invitation, error) {
 	return invitations, nil
 }
 
+func GetInvitation(gameID, userID string) (*model.Invitation, error) {
+	invitation := &model.Invitation{}
+	query := `
+		SELECT i.id, i.game_id, i.user_id, u.name, i.created_at
+		FROM game_invitations i
+		JOIN "user" u ON i.user_id = u.id
+		WHERE i.game_id = $1 AND i.user_id = $2
+	`
+	err := database.DB.QueryRow(context.Background(), query, gameID, userID).Scan(
+		&invitation.ID, &invitation.GameID, &invitation.UserID, &invitation.UserName, &invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// GetUserInvitations lists the join requests a user is still waiting on.
+func GetUserInvitations(userID string) ([]model.Invitation, error) {
+	invitations := []model.Invitation{}
+	query := `
+		SELECT i.id, i.game_id, i.user_id, u.name, g.name, g.gm_id, gm.name, i.created_at
+		FROM game_invitations i
+		JOIN "user" u ON i.user_id = u.id
+		JOIN games g ON i.game_id = g.id
+		JOIN "user" gm ON g.gm_id = gm.id
+		WHERE i.user_id = $1
+		ORDER BY i.created_at DESC
+	`
+	rows, err := database.DB.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invitation model.Invitation
+		if err := rows.Scan(&invitation.ID, &invitation.GameID, &invitation.UserID, &invitation.UserName, &invitation.GameName, &invitation.GmID, &invitation.GmName, &invitation.CreatedAt); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, nil
+}
+
+// CancelInvitation withdraws a pending request. Only the requesting user can
+// cancel it; the game ID is returned so the GM can be notified.
+func CancelInvitation(invitationID, userID string) (string, error) {
+	var gameID string
+	query := `DELETE FROM game_invitations WHERE id = $1 AND user_id = $2 RETURNING game_id`
+	err := database.DB.QueryRow(context.Background(), query, invitationID, userID).Scan(&gameID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("invitation not found")
+		}
+		return "", err
+	}
+	return gameID, nil
+}
+
 func AcceptInvitation(gameID, userID string) error {
 	ctx := context.Background()
 	tx, err := database.DB.Begin(ctx)
@@ -173,62 +247,68 @@ func DeclineInvitation(gameID, userID string) error {
 }
 
 func DeleteTable(id, userID string) error {
-	// 1. Get Game to check GM and get ImageURL
-	game, err := GetTable(id)
+	// 1. Check GM and collect the stored keys of the game image, character avatars and map backgrounds
+	var gmID, imageKey string
+	err := database.DB.QueryRow(context.Background(), "SELECT gm_id, COALESCE(image_url, '') FROM games WHERE id = $1", id).Scan(&gmID, &imageKey)
 	if err != nil {
 		return err
 	}
 
-	if game.GmID != userID {
+	if gmID != userID {
 		return errors.New("unauthorized: only the GM can delete the table")
 	}
 
-	// 2. Get all Characters for the game to get AvatarURLs
-	// We need to query this manually as we don't have a GetCharacters service handy here yet
-	// or we can just query the avatar_urls directly
-	rows, err := database.DB.Query(context.Background(), "SELECT avatar_url FROM characters WHERE game_id = $1", id)
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT c.avatar_url
+		FROM characters c
+		JOIN game_characters gc ON c.id = gc.character_id
+		WHERE gc.game_id = $1 AND c.avatar_url IS NOT NULL AND c.avatar_url != ''
+		UNION
+		SELECT background_url FROM battle_maps WHERE game_id = $1 AND background_url IS NOT NULL AND background_url != ''
+	`, id)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
-	var filesToDelete []string
-	if game.ImageURL != "" {
-		// Extract filename from URL
-		// Assuming URL format: http://host/uploads/filename
-		parts := strings.Split(game.ImageURL, "/uploads/")
-		if len(parts) == 2 {
-			filesToDelete = append(filesToDelete, filepath.Join("uploads", parts[1]))
-		}
+	var keysToDelete []string
+	if imageKey != "" {
+		keysToDelete = append(keysToDelete, imageKey)
 	}
-
 	for rows.Next() {
-		var avatarURL sql.NullString
-		if err := rows.Scan(&avatarURL); err != nil {
+		var key string
+		if err := rows.Scan(&key); err != nil {
 			continue
 		}
-		if avatarURL.Valid && avatarURL.String != "" {
-			parts := strings.Split(avatarURL.String, "/uploads/")
-			if len(parts) == 2 {
-				filesToDelete = append(filesToDelete, filepath.Join("uploads", parts[1]))
-			}
+		keysToDelete = append(keysToDelete, key)
+	}
+	rows.Close()
+
+	var handoutKeys []string
+	handoutRows, err := database.DB.Query(context.Background(), "SELECT storage_key FROM handouts WHERE game_id = $1", id)
+	if err != nil {
+		return err
+	}
+	for handoutRows.Next() {
+		var key string
+		if err := handoutRows.Scan(&key); err == nil {
+			handoutKeys = append(handoutKeys, key)
 		}
 	}
+	handoutRows.Close()
 
-	// 3. Delete Game from DB (Cascade will handle characters and players)
+	// 2. Delete Game from DB (Cascade will handle characters and players)
 	_, err = database.DB.Exec(context.Background(), "DELETE FROM games WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
 
-	// 4. Delete files from uploads/ if they exist
-	for _, path := range filesToDelete {
-		if err := os.Remove(path); err != nil {
-			// Log error but continue? Or just ignore if file doesn't exist
-			if !os.IsNotExist(err) {
-				// In a real app, we might want to log this
-				fmt.Printf("Failed to delete file %s: %v\n", path, err)
-			}
+	// 3. Delete the files from storage, external URLs are skipped
+	for _, key := range keysToDelete {
+		DeleteImage(key)
+	}
+	for _, key := range handoutKeys {
+		if err := storage.Private.Delete(context.Background(), key); err != nil {
+			fmt.Printf("Failed to delete handout file %s: %v\n", key, err)
 		}
 	}
 
@@ -330,11 +410,76 @@ func GetGamePlayers(gameID string) ([]model.Player, error) {
 	return players, nil
 }
 
+func IsGameMember(gameID, userID string) (bool, error) {
+	var isMember bool
+	err := database.DB.QueryRow(context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2)",
+		gameID, userID).Scan(&isMember)
+	return isMember, err
+}
+
 func RemovePlayer(gameID, userID string) error {
 	_, err := database.DB.Exec(context.Background(), "DELETE FROM game_players WHERE game_id = $1 AND user_id = $2", gameID, userID)
 	return err
 }
 
+// Character handling options when a player leaves a game
+const (
+	LeaveKeepCharacter     = "keep"     // Character stays assigned, restored if the player comes back
+	LeaveUnassignCharacter = "unassign" // Character stays in the game without an owner
+	LeaveArchiveCharacter  = "archive"  // Character is unassigned and hidden from the active roster
+)
+
+// LeaveTable removes the user's membership and applies their choices about
+// their character and notes in a single transaction.
+func LeaveTable(gameID, userID, characterMode string, keepNotes bool) error {
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, "DELETE FROM game_players WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("not a member of this game")
+	}
+
+	switch characterMode {
+	case LeaveKeepCharacter:
+	case LeaveUnassignCharacter:
+		_, err = tx.Exec(ctx, "UPDATE game_characters SET user_id = NULL WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	case LeaveArchiveCharacter:
+		_, err = tx.Exec(ctx, "UPDATE game_characters SET user_id = NULL, archived_at = NOW() WHERE game_id = $1 AND user_id = $2", gameID, userID)
+	default:
+		return fmt.Errorf("invalid character mode: %s", characterMode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !keepNotes {
+		_, err = tx.Exec(ctx, "DELETE FROM notes WHERE game_id = $1 AND user_id = $2", gameID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Notes of the game are no longer shared with them
+	_, err = tx.Exec(ctx, `
+		DELETE FROM note_shares s USING notes n
+		WHERE n.id = s.note_id AND n.game_id = $1 AND s.user_id = $2
+	`, gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 func UpdateGameState(gameID, state string) error {
 	query := `
 		UPDATE games
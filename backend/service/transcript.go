@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	model "questhub/models/database"
+)
+
+var ErrInvalidTranscriptFormat = errors.New("format must be markdown or html")
+
+const transcriptTimeFormat = "15:04"
+
+// markdownEscape keeps chat content from being interpreted as Markdown.
+var markdownEscape = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "#", `\#`, "|", `\|`,
+)
+
+// sessionPeriod describes when a session ran, in loc.
+func sessionPeriod(s *model.GameSession, loc *time.Location) string {
+	if s.StartedAt == nil {
+		return "Scheduled " + s.ScheduledAt.In(loc).Format("Mon Jan 2, 2006 15:04 MST")
+	}
+	period := "Started " + s.StartedAt.In(loc).Format("Mon Jan 2, 2006 15:04 MST")
+	if s.EndedAt != nil {
+		period += ", ended " + s.EndedAt.In(loc).Format("Mon Jan 2, 2006 15:04 MST")
+	} else {
+		period += ", still running"
+	}
+	return period
+}
+
+// RenderTranscript renders a session recap and its chat log as markdown or
+// html. It returns the document and its content type.
+func RenderTranscript(s *model.GameSession, messages []model.ChatMessage, format string, loc *time.Location) ([]byte, string, error) {
+	switch format {
+	case "", "markdown", "md":
+		return renderMarkdownTranscript(s, messages, loc), "text/markdown; charset=utf-8", nil
+	case "html":
+		return renderHTMLTranscript(s, messages, loc), "text/html; charset=utf-8", nil
+	}
+	return nil, "", ErrInvalidTranscriptFormat
+}
+
+func renderMarkdownTranscript(s *model.GameSession, messages []model.ChatMessage, loc *time.Location) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s - %s\n\n", markdownEscape.Replace(s.GameName), markdownEscape.Replace(s.Title))
+	fmt.Fprintf(&buf, "_%s_\n\n", sessionPeriod(s, loc))
+
+	// The recap is written by the GM in Markdown and kept as is
+	if s.Recap != "" {
+		buf.WriteString("## Recap\n\n")
+		buf.WriteString(strings.TrimSpace(s.Recap))
+		buf.WriteString("\n\n")
+	}
+
+	buf.WriteString("## Transcript\n\n")
+	if len(messages) == 0 {
+		buf.WriteString("_No messages._\n")
+	}
+	for _, m := range messages {
+		at := m.CreatedAt.In(loc).Format(transcriptTimeFormat)
+		content := markdownEscape.Replace(strings.ReplaceAll(m.Content, "\n", " "))
+		sender := markdownEscape.Replace(m.SenderName)
+		switch m.Type {
+		case "EVENT":
+			fmt.Fprintf(&buf, "- `%s` _%s: %s_\n", at, sender, content)
+		case "CHAT_PRIVATE":
+			fmt.Fprintf(&buf, "- `%s` **%s** (private): %s\n", at, sender, content)
+		default:
+			fmt.Fprintf(&buf, "- `%s` **%s**: %s\n", at, sender, content)
+		}
+	}
+	return buf.Bytes()
+}
+
+func renderHTMLTranscript(s *model.GameSession, messages []model.ChatMessage, loc *time.Location) []byte {
+	var buf bytes.Buffer
+	title := html.EscapeString(s.GameName + " - " + s.Title)
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&buf, "<title>%s</title>\n", title)
+	buf.WriteString("<style>body{font-family:sans-serif;max-width:48rem;margin:2rem auto;line-height:1.5}" +
+		".recap{white-space:pre-wrap}time{color:#888;font-family:monospace;margin-right:.5rem}" +
+		".event{font-style:italic;color:#555}.private{color:#7a4}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&buf, "<h1>%s</h1>\n<p><em>%s</em></p>\n", title, html.EscapeString(sessionPeriod(s, loc)))
+
+	if s.Recap != "" {
+		fmt.Fprintf(&buf, "<h2>Recap</h2>\n<div class=\"recap\">%s</div>\n", html.EscapeString(strings.TrimSpace(s.Recap)))
+	}
+
+	buf.WriteString("<h2>Transcript</h2>\n")
+	if len(messages) == 0 {
+		buf.WriteString("<p><em>No messages.</em></p>\n</body>\n</html>\n")
+		return buf.Bytes()
+	}
+	buf.WriteString("<ul>\n")
+	for _, m := range messages {
+		at := m.CreatedAt.In(loc)
+		class := "chat"
+		label := ""
+		switch m.Type {
+		case "EVENT":
+			class = "event"
+		case "CHAT_PRIVATE":
+			class = "private"
+			label = " (private)"
+		}
+		fmt.Fprintf(&buf, "<li class=\"%s\"><time datetime=\"%s\">%s</time><strong>%s</strong>%s: %s</li>\n",
+			class, at.Format(time.RFC3339), at.Format(transcriptTimeFormat),
+			html.EscapeString(m.SenderName), label, html.EscapeString(m.Content))
+	}
+	buf.WriteString("</ul>\n</body>\n</html>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"questhub/config"
+	"questhub/database"
+	"questhub/imaging"
+	model "questhub/models/database"
+	"questhub/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StoredImage describes a processed upload. Keys go to the database, URLs to clients.
+type StoredImage struct {
+	Key   string            `json:"key"`
+	URL   string            `json:"url"`   // Re-encoded original
+	Sizes map[string]string `json:"sizes"` // Thumbnail URLs by size name
+}
+
+// thumbnailKey derives a thumbnail key from the original's: "123.jpg" -> "123_small.jpg".
+func thumbnailKey(key, size string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + size + ext
+}
+
+// ErrQuotaExceeded is returned when an upload would go over the owner's quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// ErrUploadNotOwned is returned when a client refers to an upload made by someone else.
+var ErrUploadNotOwned = errors.New("images must be uploaded by you")
+
+// CheckUploadKey validates an image value about to be stored, after
+// storage.KeyFromURL. External URLs are accepted, keys only when ownerID made
+// the upload or the key is in kept, the images already on the record being
+// changed. Otherwise anyone could attach another user's files by key.
+func CheckUploadKey(key, ownerID string, kept map[string]bool) error {
+	if key == "" || kept[key] || strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+		return nil
+	}
+	var owned bool
+	err := database.DB.QueryRow(context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM uploads WHERE storage_key = $1 AND owner_id = $2)", key, ownerID,
+	).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrUploadNotOwned
+	}
+	return nil
+}
+
+// checkItemImages runs CheckUploadKey on the image of every item of a normalized list.
+func checkItemImages(items json.RawMessage, ownerID string, kept map[string]bool) error {
+	var list []map[string]any
+	if err := json.Unmarshal(items, &list); err != nil {
+		return ErrInvalidItems
+	}
+	for _, item := range list {
+		key, _ := item["image_url"].(string)
+		if err := CheckUploadKey(key, ownerID, kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeptUploads collects the image keys of a record before it is changed, from
+// single values and item lists as returned to clients.
+func KeptUploads(values []string, itemLists ...json.RawMessage) map[string]bool {
+	kept := map[string]bool{}
+	for _, value := range values {
+		if value != "" {
+			kept[storage.KeyFromURL(value)] = true
+		}
+	}
+	for _, items := range itemLists {
+		var list []map[string]any
+		json.Unmarshal(items, &list)
+		for _, item := range list {
+			if url, _ := item["image_url"].(string); url != "" {
+				kept[storage.KeyFromURL(url)] = true
+			}
+		}
+	}
+	return kept
+}
+
+// StorageUsage is a user's upload accounting.
+type StorageUsage struct {
+	UsedBytes    int64          `json:"used_bytes"`
+	HandoutBytes int64          `json:"handout_bytes"` // Included in UsedBytes
+	QuotaBytes   int64          `json:"quota_bytes"`   // 0 means unlimited
+	FileCount    int            `json:"file_count"`
+	Uploads      []model.Upload `json:"uploads"`
+}
+
+// storedImageFromKey rebuilds the URLs of an image stored earlier.
+func storedImageFromKey(key string, kind imaging.Kind) *StoredImage {
+	image := &StoredImage{Key: key, URL: storage.Default.URL(key), Sizes: map[string]string{}}
+	for _, size := range imaging.KindSizeNames(kind) {
+		image.Sizes[size] = storage.Default.URL(thumbnailKey(key, size))
+	}
+	return image
+}
+
+func usedStorage(q rowQuerier, ownerID string) (int64, error) {
+	var used int64
+	err := q.QueryRow(context.Background(), `
+		SELECT (SELECT COALESCE(SUM(size), 0) FROM uploads WHERE owner_id = $1)
+		     + (SELECT COALESCE(SUM(size), 0) FROM handouts WHERE created_by = $1)
+	`, ownerID).Scan(&used)
+	return used, err
+}
+
+// rowQuerier is implemented by both the pool and transactions.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// lockUploadQuota serializes the uploads of an owner until the transaction
+// ends, so that checking the quota and recording the upload are atomic.
+func lockUploadQuota(tx pgx.Tx, ownerID string) error {
+	_, err := tx.Exec(context.Background(), "SELECT pg_advisory_xact_lock(hashtext('upload_quota:' || $1))", ownerID)
+	return err
+}
+
+// reuseUpload returns the key of an identical upload, marking it as used so
+// the GC does not collect it during the grace period.
+func reuseUpload(q rowQuerier, ownerID, hash string, kind imaging.Kind) (string, error) {
+	var key string
+	err := q.QueryRow(context.Background(), `
+		UPDATE uploads SET last_used_at = NOW()
+		WHERE owner_id = $1 AND content_hash = $2 AND kind = $3
+		RETURNING storage_key
+	`, ownerID, hash, string(kind)).Scan(&key)
+	return key, err
+}
+
+// SaveImage processes an uploaded image and stores the original and its thumbnails.
+// Uploading the same content twice returns the existing image without using quota.
+func SaveImage(data []byte, kind imaging.Kind, ownerID string) (*StoredImage, error) {
+	ctx := context.Background()
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	existingKey, err := reuseUpload(database.DB, ownerID, hash, kind)
+	if err == nil {
+		return storedImageFromKey(existingKey, kind), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	variants, err := imaging.Process(data, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, variant := range variants {
+		total += int64(len(variant.Data))
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUploadQuota(tx, ownerID); err != nil {
+		return nil, err
+	}
+	// The same content may have been stored while we were processing it
+	existingKey, err = reuseUpload(tx, ownerID, hash, kind)
+	if err == nil {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return storedImageFromKey(existingKey, kind), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if config.UploadQuota > 0 {
+		used, err := usedStorage(tx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if used+total > config.UploadQuota {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	base := fmt.Sprintf("%d", time.Now().UnixNano())
+	image := &StoredImage{Sizes: map[string]string{}}
+	for _, variant := range variants {
+		if variant.Name == imaging.OriginalVariant {
+			image.Key = base + variant.Ext
+			break
+		}
+	}
+
+	for _, variant := range variants {
+		key := image.Key
+		if variant.Name != imaging.OriginalVariant {
+			key = thumbnailKey(image.Key, variant.Name)
+		}
+
+		if err := storage.Default.Put(ctx, key, variant.Data, variant.ContentType); err != nil {
+			return nil, err
+		}
+
+		if variant.Name == imaging.OriginalVariant {
+			image.URL = storage.Default.URL(key)
+		} else {
+			image.Sizes[variant.Name] = storage.Default.URL(key)
+		}
+	}
+
+	// Files stored by a failed transaction are collected as orphans
+	_, err = tx.Exec(ctx, `
+		INSERT INTO uploads (owner_id, storage_key, kind, content_hash, size)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ownerID, image.Key, string(kind), hash, total)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// GetStorageUsage returns the uploads of a user and their total size.
+func GetStorageUsage(ownerID string) (*StorageUsage, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT id, owner_id, storage_key, kind, content_hash, size, reference_count, created_at
+		FROM uploads
+		WHERE owner_id = $1
+		ORDER BY created_at DESC
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := &StorageUsage{QuotaBytes: config.UploadQuota, Uploads: []model.Upload{}}
+	for rows.Next() {
+		var upload model.Upload
+		if err := rows.Scan(&upload.ID, &upload.OwnerID, &upload.Key, &upload.Kind, &upload.ContentHash, &upload.Size, &upload.ReferenceCount, &upload.CreatedAt); err != nil {
+			return nil, err
+		}
+		upload.URL = storage.PublicURL(upload.Key)
+		usage.UsedBytes += upload.Size
+		usage.Uploads = append(usage.Uploads, upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	usage.FileCount = len(usage.Uploads)
+
+	err = database.DB.QueryRow(context.Background(),
+		"SELECT COALESCE(SUM(size), 0) FROM handouts WHERE created_by = $1", ownerID,
+	).Scan(&usage.HandoutBytes)
+	if err != nil {
+		return nil, err
+	}
+	usage.UsedBytes += usage.HandoutBytes
+
+	return usage, nil
+}
+
+// isUploadReferenced reports whether anything listed by uploadReferencesQuery
+// still uses the key. Deduplicated uploads can be shared, so files are only
+// deleted once unused.
+func isUploadReferenced(key string) (bool, error) {
+	var referenced bool
+	err := database.DB.QueryRow(context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM ("+uploadReferencesQuery+") refs WHERE refs.ref = $1)", key,
+	).Scan(&referenced)
+	return referenced, err
+}
+
+// DeleteImage removes a stored image and any thumbnail generated for it.
+// Values that are not keys of ours (external URLs) are ignored.
+func DeleteImage(key string) {
+	key = storage.KeyFromURL(key)
+	if key == "" || strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+		return
+	}
+
+	if referenced, err := isUploadReferenced(key); err != nil || referenced {
+		return
+	}
+
+	ctx := context.Background()
+	keys := []string{key}
+	for _, size := range imaging.SizeNames() {
+		keys = append(keys, thumbnailKey(key, size))
+	}
+
+	for _, k := range keys {
+		if err := storage.Default.Delete(ctx, k); err != nil {
+			fmt.Printf("Failed to delete file %s: %v\n", k, err)
+		}
+	}
+
+	if _, err := database.DB.Exec(ctx, "DELETE FROM uploads WHERE storage_key = $1", key); err != nil {
+		fmt.Printf("Failed to delete upload record %s: %v\n", key, err)
+	}
+}
+
+// resolveCharacterURLs replaces stored keys on a character with public URLs.
+func resolveCharacterURLs(char *model.Character) {
+	if char.AvatarURL != nil {
+		url := storage.PublicURL(*char.AvatarURL)
+		char.AvatarURL = &url
+	}
+
+	char.Inventory = resolveInventoryURLs(char.Inventory)
+}
+
+// resolveInventoryURLs turns the image keys of inventory items into URLs.
+func resolveInventoryURLs(inventory json.RawMessage) json.RawMessage {
+	if len(inventory) == 0 {
+		return inventory
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(inventory, &items); err != nil {
+		return inventory
+	}
+	for _, item := range items {
+		if key, ok := item["image_url"].(string); ok {
+			item["image_url"] = storage.PublicURL(key)
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return inventory
+	}
+	return data
+}
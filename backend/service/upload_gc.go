@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"questhub/database"
+	"questhub/imaging"
+	"questhub/storage"
+)
+
+// UploadGCReport summarizes a scan of the upload storage.
+type UploadGCReport struct {
+	Scanned    int              `json:"scanned"`
+	Orphans    []storage.Object `json:"orphans"`
+	Deleted    int              `json:"deleted"`
+	FreedBytes int64            `json:"freed_bytes"`
+	DryRun     bool             `json:"dry_run"`
+}
+
+// markdownUploadRefs selects the file name of every link or image in the
+// Markdown column, so an upload embedded as ![](url) counts as used. Any URL
+// ending in a key keeps it, which errs on the side of keeping files.
+func markdownUploadRefs(table, column string) string {
+	return `
+	SELECT regexp_replace(regexp_replace(m.link[1], '[?#].*$', ''), '^.*/', '')
+	FROM ` + table + ` t, regexp_matches(t.` + column + `, '\]\(\s*<?([^)\s>]+)', 'g') AS m(link)`
+}
+
+// uploadReferencesQuery lists every stored value pointing to an upload, once per use.
+var uploadReferencesQuery = `
+	SELECT image_url AS ref FROM games WHERE image_url IS NOT NULL AND image_url != ''
+	UNION ALL
+	SELECT background_url FROM battle_maps WHERE background_url IS NOT NULL AND background_url != ''
+	UNION ALL
+	SELECT avatar_url FROM characters WHERE avatar_url IS NOT NULL AND avatar_url != ''
+	UNION ALL
+	SELECT item->>'image_url'
+	FROM characters c,
+	     jsonb_array_elements(CASE WHEN jsonb_typeof(c.inventory) = 'array' THEN c.inventory ELSE '[]'::jsonb END) AS item
+	WHERE COALESCE(item->>'image_url', '') != ''
+	UNION ALL
+	SELECT item->>'image_url'
+	FROM quests q, jsonb_array_elements(q.reward_items) AS item
+	WHERE COALESCE(item->>'image_url', '') != ''
+	UNION ALL
+	SELECT item->>'image_url' FROM random_table_entries WHERE COALESCE(item->>'image_url', '') != ''
+	UNION ALL
+	SELECT item->>'image_url'
+	FROM party_stashes s, jsonb_array_elements(s.items) AS item
+	WHERE COALESCE(item->>'image_url', '') != ''
+	UNION ALL
+	SELECT item->>'image_url'
+	FROM stash_ledger l, jsonb_array_elements(l.items) AS item
+	WHERE COALESCE(item->>'image_url', '') != ''
+	UNION ALL` + markdownUploadRefs("wiki_pages", "content") + `
+	UNION ALL` + markdownUploadRefs("wiki_revisions", "content") + `
+	UNION ALL` + markdownUploadRefs("notes", "content")
+
+// refreshUploadReferences updates the reference counts shown in storage usage.
+func refreshUploadReferences() error {
+	_, err := database.DB.Exec(context.Background(), `
+		UPDATE uploads u
+		SET reference_count = (SELECT COUNT(*) FROM (`+uploadReferencesQuery+`) refs WHERE refs.ref = u.storage_key)
+	`)
+	return err
+}
+
+// referencedUploadKeys returns every storage key still used by a game or a
+// character, thumbnails included.
+func referencedUploadKeys() (map[string]bool, error) {
+	rows, err := database.DB.Query(context.Background(), "SELECT DISTINCT ref FROM ("+uploadReferencesQuery+") refs")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[string]bool{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		key := storage.KeyFromURL(value)
+		keys[key] = true
+		for _, size := range imaging.SizeNames() {
+			keys[thumbnailKey(key, size)] = true
+		}
+	}
+	return keys, rows.Err()
+}
+
+// CollectOrphanedUploads finds stored files no longer referenced by the database
+// and older than grace, and deletes them unless dryRun is set.
+func CollectOrphanedUploads(grace time.Duration, dryRun bool) (*UploadGCReport, error) {
+	ctx := context.Background()
+	report := &UploadGCReport{Orphans: []storage.Object{}, DryRun: dryRun}
+
+	// List before reading references: a file uploaded in between is either
+	// missing from the listing or protected by the grace period.
+	objects, err := storage.Default.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Scanned = len(objects)
+
+	referenced, err := referencedUploadKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	// Deduplicated uploads reused recently are kept like new files
+	cutoff := time.Now().Add(-grace)
+	rows, err := database.DB.Query(ctx, "SELECT storage_key FROM uploads WHERE last_used_at > $1", cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		referenced[key] = true
+		for _, size := range imaging.SizeNames() {
+			referenced[thumbnailKey(key, size)] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		if referenced[object.Key] || object.LastModified.After(cutoff) {
+			continue
+		}
+		report.Orphans = append(report.Orphans, object)
+
+		if dryRun {
+			continue
+		}
+		if err := storage.Default.Delete(ctx, object.Key); err != nil {
+			fmt.Printf("Failed to delete orphaned upload %s: %v\n", object.Key, err)
+			continue
+		}
+		report.Deleted++
+		report.FreedBytes += object.Size
+
+		// Thumbnails have no record, deleting by key is a no-op for them
+		if _, err := database.DB.Exec(ctx, "DELETE FROM uploads WHERE storage_key = $1", object.Key); err != nil {
+			fmt.Printf("Failed to delete upload record %s: %v\n", object.Key, err)
+		}
+	}
+
+	if !dryRun {
+		if err := refreshUploadReferences(); err != nil {
+			fmt.Printf("Failed to refresh upload references: %v\n", err)
+		}
+	}
+
+	return report, nil
+}
+
+// StartUploadGC collects orphaned uploads every interval until the process exits.
+func StartUploadGC(interval, grace time.Duration) {
+	if interval <= 0 {
+		log.Println("Upload GC disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := CollectOrphanedUploads(grace, false)
+			if err != nil {
+				log.Printf("Upload GC failed: %v", err)
+				continue
+			}
+			if len(report.Orphans) > 0 {
+				log.Printf("Upload GC: %d files scanned, %d orphans deleted, %d bytes freed", report.Scanned, report.Deleted, report.FreedBytes)
+			}
+		}
+	}()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"questhub/database"
+	"questhub/storage"
 	"time"
 )
 
@@ -14,12 +15,21 @@ type UserStats struct {
 }
 
 type UserCampaign struct {
-	GameID          string    `json:"game_id"`
-	GameName        string    `json:"game_name"`
-	GameImageURL    string    `json:"game_image_url"`
-	CharacterName   string    `json:"character_name"`
-	CharacterAvatar string    `json:"character_avatar_url"`
-	JoinedAt        time.Time `json:"joined_at"`
+	GameID          string       `json:"game_id"`
+	GameName        string       `json:"game_name"`
+	GameImageURL    string       `json:"game_image_url"`
+	CharacterName   string       `json:"character_name"`
+	CharacterAvatar string       `json:"character_avatar_url"`
+	JoinedAt        time.Time    `json:"joined_at"`
+	NextSession     *NextSession `json:"next_session"`
+}
+
+// NextSession is the upcoming scheduled session of a campaign and the user's answer.
+type NextSession struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	RSVP        string    `json:"rsvp,omitempty"`
 }
 
 func GetUserStats(userID string) (*UserStats, error) {
@@ -56,10 +66,19 @@ func GetUserCampaigns(userID string) ([]UserCampaign, error) {
 	rows, err := database.DB.Query(context.Background(), `
 		SELECT 
 			g.id, g.name, COALESCE(g.image_url, ''), 
-			c.name, COALESCE(c.avatar_url, ''), gc.assigned_at
+			c.name, COALESCE(c.avatar_url, ''), gc.assigned_at,
+			ns.id, ns.title, ns.scheduled_at, COALESCE(r.status, '')
 		FROM characters c
 		JOIN game_characters gc ON c.id = gc.character_id
 		JOIN games g ON g.id = gc.game_id
+		LEFT JOIN LATERAL (
+			SELECT s.id, s.title, s.scheduled_at
+			FROM game_sessions s
+			WHERE s.game_id = g.id AND s.cancelled_at IS NULL AND s.scheduled_at >= NOW()
+			ORDER BY s.scheduled_at
+			LIMIT 1
+		) ns ON TRUE
+		LEFT JOIN session_rsvps r ON r.session_id = ns.id AND r.user_id = $1
 		WHERE gc.user_id = $1
 		ORDER BY gc.assigned_at DESC
 	`, userID)
@@ -71,14 +90,34 @@ func GetUserCampaigns(userID string) ([]UserCampaign, error) {
 	var campaigns []UserCampaign
 	for rows.Next() {
 		var c UserCampaign
+		var nextID, nextTitle *string
+		var nextAt *time.Time
+		var rsvp string
 		// We need to handle potential NULLs if we change the query, but here inner join ensures game exists.
 		// However, image_url and avatar_url can be null in DB, handled by COALESCE in SQL.
 		// But in Go, we need to map to string. The SQL COALESCE handles it.
-		if err := rows.Scan(&c.GameID, &c.GameName, &c.GameImageURL, &c.CharacterName, &c.CharacterAvatar, &c.JoinedAt); err != nil {
+		if err := rows.Scan(&c.GameID, &c.GameName, &c.GameImageURL, &c.CharacterName, &c.CharacterAvatar, &c.JoinedAt,
+			&nextID, &nextTitle, &nextAt, &rsvp); err != nil {
 			return nil, fmt.Errorf("failed to scan campaign row: %w", err)
 		}
+		if nextID != nil {
+			c.NextSession = &NextSession{ID: *nextID, Title: *nextTitle, ScheduledAt: *nextAt, RSVP: rsvp}
+		}
+		c.GameImageURL = storage.PublicURL(c.GameImageURL)
+		c.CharacterAvatar = storage.PublicURL(c.CharacterAvatar)
 		campaigns = append(campaigns, c)
 	}
 
 	return campaigns, nil
 }
+
+// EnsureUser creates a user row if it does not exist yet. better-auth owns this
+// table in normal runs; this is only used to seed dev auth users.
+func EnsureUser(id, name, email string) error {
+	_, err := database.DB.Exec(context.Background(), `
+		INSERT INTO "user" (id, name, email, "emailVerified")
+		VALUES ($1, $2, $3, TRUE)
+		ON CONFLICT (id) DO NOTHING
+	`, id, name, email)
+	return err
+}
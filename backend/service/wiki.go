@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"questhub/database"
+	model "questhub/models/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidWikiPage   = errors.New("a title and a category among general, location, npc, faction and history are required")
+	ErrWikiPageExists    = errors.New("a page with this title already exists")
+	ErrWikiTitleReserved = errors.New("this title is reserved, choose another one")
+)
+
+// Slugs that would collide with the static wiki routes (GET /wiki/search)
+var reservedWikiSlugs = map[string]bool{"search": true}
+
+type WikiPageInput struct {
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Content  string `json:"content"`
+}
+
+func (in *WikiPageInput) validate() error {
+	if in.Category == "" {
+		in.Category = "general"
+	}
+	switch in.Category {
+	case "general", "location", "npc", "faction", "history":
+	default:
+		return ErrInvalidWikiPage
+	}
+	slug := slugify(in.Title)
+	if slug == "" {
+		return ErrInvalidWikiPage
+	}
+	if reservedWikiSlugs[slug] {
+		return ErrWikiTitleReserved
+	}
+	return nil
+}
+
+// savePageContent stores the content of a page with its links, and records a revision.
+func savePageContent(tx pgx.Tx, pageID, userID string, input WikiPageInput) error {
+	ctx := context.Background()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM wiki_links WHERE page_id = $1", pageID); err != nil {
+		return err
+	}
+	for _, link := range wikiLinks(input.Content) {
+		if _, err := tx.Exec(ctx, "INSERT INTO wiki_links (page_id, target_slug, secret) VALUES ($1, $2, $3)",
+			pageID, link.Slug, link.Secret); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO wiki_revisions (page_id, title, category, content, edited_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, pageID, input.Title, input.Category, input.Content, userID)
+	return err
+}
+
+func slugTaken(tx pgx.Tx, gameID, slug, exceptID string) (bool, error) {
+	var taken bool
+	err := tx.QueryRow(context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM wiki_pages WHERE game_id = $1 AND slug = $2 AND id::text != $3)",
+		gameID, slug, exceptID).Scan(&taken)
+	return taken, err
+}
+
+func CreateWikiPage(gameID, userID string, input WikiPageInput) (*model.WikiPage, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	slug := slugify(input.Title)
+	public, _ := splitSecretSections(input.Content)
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	if taken, err := slugTaken(tx, gameID, slug, ""); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrWikiPageExists
+	}
+
+	var pageID string
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO wiki_pages (game_id, title, slug, category, content, public_content, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id
+	`, gameID, input.Title, slug, input.Category, input.Content, public, userID).Scan(&pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := savePageContent(tx, pageID, userID, input); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetWikiPage(gameID, slug, true)
+}
+
+// UpdateWikiPage saves a new version of a page. Renaming it changes its slug;
+// links to the old title then point to a missing page.
+func UpdateWikiPage(gameID, slug, userID string, input WikiPageInput) (*model.WikiPage, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	newSlug := slugify(input.Title)
+	public, _ := splitSecretSections(input.Content)
+
+	tx, err := database.DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var pageID string
+	err = tx.QueryRow(context.Background(), "SELECT id FROM wiki_pages WHERE game_id = $1 AND slug = $2 FOR UPDATE", gameID, slug).Scan(&pageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("wiki page not found")
+		}
+		return nil, err
+	}
+
+	if taken, err := slugTaken(tx, gameID, newSlug, pageID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrWikiPageExists
+	}
+
+	_, err = tx.Exec(context.Background(), `
+		UPDATE wiki_pages
+		SET title = $1, slug = $2, category = $3, content = $4, public_content = $5, updated_by = $6, updated_at = NOW()
+		WHERE id = $7
+	`, input.Title, newSlug, input.Category, input.Content, public, userID, pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := savePageContent(tx, pageID, userID, input); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return GetWikiPage(gameID, newSlug, true)
+}
+
+func DeleteWikiPage(gameID, slug string) error {
+	result, err := database.DB.Exec(context.Background(), "DELETE FROM wiki_pages WHERE game_id = $1 AND slug = $2", gameID, slug)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("wiki page not found")
+	}
+	return nil
+}
+
+// GetWikiPages lists the pages of a game without their content, optionally in one category.
+func GetWikiPages(gameID, category string) ([]model.WikiPage, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT id, game_id, title, slug, category, updated_by, created_at, updated_at
+		FROM wiki_pages
+		WHERE game_id = $1 AND ($2 = '' OR category = $2)
+		ORDER BY category, title
+	`, gameID, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pages := []model.WikiPage{}
+	for rows.Next() {
+		var p model.WikiPage
+		if err := rows.Scan(&p.ID, &p.GameID, &p.Title, &p.Slug, &p.Category, &p.UpdatedBy, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// GetWikiPage returns a page with its links and backlinks. Players get the
+// content without secret sections, and no link found only in them.
+func GetWikiPage(gameID, slug string, isGM bool) (*model.WikiPage, error) {
+	p := &model.WikiPage{Links: []model.WikiLink{}, Backlinks: []model.WikiLink{}}
+	var content, public string
+	err := database.DB.QueryRow(context.Background(), `
+		SELECT id, game_id, title, slug, category, content, public_content, updated_by, created_at, updated_at
+		FROM wiki_pages WHERE game_id = $1 AND slug = $2
+	`, gameID, slug).Scan(&p.ID, &p.GameID, &p.Title, &p.Slug, &p.Category, &content, &public, &p.UpdatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("wiki page not found")
+		}
+		return nil, err
+	}
+	p.Content = public
+	if isGM {
+		p.Content = content
+	}
+
+	// Link titles come from the target page when it exists
+	refs := extractWikiLinks(p.Content)
+	if len(refs) > 0 {
+		slugs := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			slugs = append(slugs, ref.Slug)
+		}
+		rows, err := database.DB.Query(context.Background(),
+			"SELECT slug, title FROM wiki_pages WHERE game_id = $1 AND slug = ANY($2)", gameID, slugs)
+		if err != nil {
+			return nil, err
+		}
+		titles := map[string]string{}
+		for rows.Next() {
+			var s, t string
+			if err := rows.Scan(&s, &t); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			titles[s] = t
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			link := model.WikiLink{Slug: ref.Slug, Title: ref.Title}
+			if title, ok := titles[ref.Slug]; ok {
+				link.Title, link.Exists = title, true
+			}
+			p.Links = append(p.Links, link)
+		}
+	}
+
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT p.slug, p.title
+		FROM wiki_links l
+		JOIN wiki_pages p ON p.id = l.page_id
+		WHERE p.game_id = $1 AND l.target_slug = $2 AND p.id != $3 AND ($4 OR NOT l.secret)
+		ORDER BY p.title
+	`, gameID, p.Slug, p.ID, isGM)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link := model.WikiLink{Exists: true}
+		if err := rows.Scan(&link.Slug, &link.Title); err != nil {
+			return nil, err
+		}
+		p.Backlinks = append(p.Backlinks, link)
+	}
+	return p, rows.Err()
+}
+
+func GetWikiRevisions(gameID, slug string) ([]model.WikiRevision, error) {
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT r.id, r.page_id, r.title, r.category, r.content, r.edited_by, COALESCE(u.name, ''), r.created_at
+		FROM wiki_revisions r
+		JOIN wiki_pages p ON p.id = r.page_id
+		LEFT JOIN "user" u ON u.id = r.edited_by
+		WHERE p.game_id = $1 AND p.slug = $2
+		ORDER BY r.created_at DESC
+	`, gameID, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.WikiRevision{}
+	for rows.Next() {
+		var r model.WikiRevision
+		if err := rows.Scan(&r.ID, &r.PageID, &r.Title, &r.Category, &r.Content, &r.EditedBy, &r.EditedByName, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// RestoreWikiRevision saves an old revision as the new version of the page.
+func RestoreWikiRevision(gameID, slug, revisionID, userID string) (*model.WikiPage, error) {
+	var input WikiPageInput
+	err := database.DB.QueryRow(context.Background(), `
+		SELECT r.title, r.category, r.content
+		FROM wiki_revisions r
+		JOIN wiki_pages p ON p.id = r.page_id
+		WHERE p.game_id = $1 AND p.slug = $2 AND r.id = $3
+	`, gameID, slug, revisionID).Scan(&input.Title, &input.Category, &input.Content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("revision not found")
+		}
+		return nil, err
+	}
+	return UpdateWikiPage(gameID, slug, userID, input)
+}
+
+// SearchWiki finds pages matching a web-style query ("tavern -burned").
+// Players only search the content they can read.
+func SearchWiki(gameID, query string, isGM bool) ([]model.WikiSearchResult, error) {
+	column, text := "search_public", "public_content"
+	if isGM {
+		column, text = "search_full", "content"
+	}
+
+	rows, err := database.DB.Query(context.Background(), `
+		SELECT title, slug, category,
+		       ts_headline('simple', `+text+`, q, 'StartSel=**, StopSel=**, MaxWords=25, MinWords=10')
+		FROM wiki_pages, websearch_to_tsquery('simple', $2) q
+		WHERE game_id = $1 AND `+column+` @@ q
+		ORDER BY ts_rank(`+column+`, q) DESC, title
+		LIMIT 50
+	`, gameID, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.WikiSearchResult{}
+	for rows.Next() {
+		var r model.WikiSearchResult
+		if err := rows.Scan(&r.Title, &r.Slug, &r.Category, &r.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// GM secret sections are fenced blocks:
+//
+//	:::gm
+//	The innkeeper is a vampire.
+//	:::
+const (
+	secretSectionStart = ":::gm"
+	secretSectionEnd   = ":::"
+)
+
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|[^\[\]]*)?\]\]`)
+
+// slugify turns a page title into the slug used by links and URLs.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// splitSecretSections returns the content players may read, and the secret
+// sections on their own. An unterminated section runs to the end of the page.
+func splitSecretSections(content string) (public, secret string) {
+	var pub, sec strings.Builder
+	inSecret := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inSecret && trimmed == secretSectionStart:
+			inSecret = true
+		case inSecret && trimmed == secretSectionEnd:
+			inSecret = false
+		case inSecret:
+			sec.WriteString(line)
+		default:
+			pub.WriteString(line)
+		}
+	}
+	return pub.String(), sec.String()
+}
+
+type wikiLinkRef struct {
+	Title  string
+	Slug   string
+	Secret bool // Only linked from a secret section
+}
+
+func extractWikiLinks(content string) []wikiLinkRef {
+	refs := []wikiLinkRef{}
+	seen := map[string]bool{}
+	for _, m := range wikiLinkPattern.FindAllStringSubmatch(content, -1) {
+		title := strings.TrimSpace(m[1])
+		slug := slugify(title)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		refs = append(refs, wikiLinkRef{Title: title, Slug: slug})
+	}
+	return refs
+}
+
+// wikiLinks lists the links of a page, flagging those players cannot see.
+func wikiLinks(content string) []wikiLinkRef {
+	public, _ := splitSecretSections(content)
+	visible := map[string]bool{}
+	for _, ref := range extractWikiLinks(public) {
+		visible[ref.Slug] = true
+	}
+	refs := extractWikiLinks(content)
+	for i := range refs {
+		refs[i].Secret = !visible[refs[i].Slug]
+	}
+	return refs
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"questhub/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidXP         = errors.New("xp amount must be positive, and at least one per character when split")
+	ErrInvalidThresholds = errors.New("thresholds must start at 0 and increase strictly, 100 levels at most")
+	ErrNoCharacters      = errors.New("no player character to award xp to")
+)
+
+const maxLevels = 100
+
+// characterLevelColumn computes the level of c in the game of gc: the number
+// of thresholds its experience reached.
+const characterLevelColumn = `(SELECT GREATEST(COUNT(*), 1) FROM games lg, unnest(lg.level_thresholds) lt WHERE lg.id = gc.game_id AND lt <= c.experience)::int`
+
+// LevelForXP returns the level reached with xp. Levels start at 1.
+func LevelForXP(thresholds []int, xp int) int {
+	level := sort.Search(len(thresholds), func(i int) bool { return thresholds[i] > xp })
+	if level < 1 {
+		return 1
+	}
+	return level
+}
+
+func GetLevelThresholds(gameID string) ([]int, error) {
+	var thresholds []int
+	err := database.DB.QueryRow(context.Background(), "SELECT level_thresholds FROM games WHERE id = $1", gameID).Scan(&thresholds)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("game not found")
+		}
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+func SetLevelThresholds(gameID string, thresholds []int) error {
+	if len(thresholds) == 0 || len(thresholds) > maxLevels || thresholds[0] != 0 {
+		return ErrInvalidThresholds
+	}
+	for i := 1; i < len(thresholds); i++ {
+		if thresholds[i] <= thresholds[i-1] {
+			return ErrInvalidThresholds
+		}
+	}
+
+	result, err := database.DB.Exec(context.Background(), "UPDATE games SET level_thresholds = $1 WHERE id = $2", thresholds, gameID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("game not found")
+	}
+	return nil
+}
+
+type XPAward struct {
+	CharacterID   string  `json:"character_id"`
+	Name          string  `json:"name"`
+	UserID        *string `json:"user_id"`
+	Amount        int     `json:"amount"`
+	Experience    int     `json:"experience"`
+	PreviousLevel int     `json:"previous_level"`
+	Level         int     `json:"level"`
+}
+
+// AwardXP gives experience to the listed characters, or to every active
+// player character when the list is empty. With split the amount is shared
+// between them, rounded down, otherwise each one gets it in full.
+func AwardXP(gameID string, characterIDs []string, amount int, split bool) ([]XPAward, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidXP
+	}
+	characterIDs = uniqueStrings(characterIDs)
+
+	ctx := context.Background()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var thresholds []int
+	if err := tx.QueryRow(ctx, "SELECT level_thresholds FROM games WHERE id = $1", gameID).Scan(&thresholds); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("game not found")
+		}
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.id, c.name, gc.user_id, c.experience
+		FROM characters c
+		JOIN game_characters gc ON gc.character_id = c.id
+		WHERE gc.game_id = $1
+		AND (
+			(cardinality($2::uuid[]) = 0 AND c.type = 'PLAYER' AND gc.user_id IS NOT NULL AND gc.archived_at IS NULL)
+			OR c.id = ANY($2::uuid[])
+		)
+		ORDER BY c.name
+		FOR UPDATE OF c
+	`, gameID, characterIDs)
+	if err != nil {
+		return nil, err
+	}
+	awards := []XPAward{}
+	for rows.Next() {
+		var a XPAward
+		if err := rows.Scan(&a.CharacterID, &a.Name, &a.UserID, &a.Experience); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		awards = append(awards, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(awards) == 0 {
+		return nil, ErrNoCharacters
+	}
+	if len(characterIDs) > 0 && len(awards) != len(characterIDs) {
+		return nil, ErrUnknownCharacter
+	}
+
+	share := amount
+	if split {
+		share = amount / len(awards)
+		if share == 0 {
+			return nil, ErrInvalidXP
+		}
+	}
+	for i := range awards {
+		a := &awards[i]
+		a.Amount = share
+		a.PreviousLevel = LevelForXP(thresholds, a.Experience)
+		a.Experience += share
+		a.Level = LevelForXP(thresholds, a.Experience)
+		if _, err := tx.Exec(ctx, "UPDATE characters SET experience = $1 WHERE id = $2", a.Experience, a.CharacterID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return awards, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 stores objects in an S3-compatible bucket (AWS, MinIO, R2...).
+type S3 struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3FromEnv configures the public bucket from S3_* variables. Against a
+// local MinIO: S3_ENDPOINT=localhost:9000 S3_USE_SSL=false.
+func NewS3FromEnv() (*S3, error) {
+	return openS3Bucket(os.Getenv("S3_BUCKET"))
+}
+
+// NewPrivateS3FromEnv configures the bucket from S3_PRIVATE_BUCKET, which
+// must not allow anonymous reads. It has no usable public URL.
+func NewPrivateS3FromEnv() (*S3, error) {
+	bucket := os.Getenv("S3_PRIVATE_BUCKET")
+	if bucket == "" {
+		return nil, errors.New("S3_PRIVATE_BUCKET is required for the s3 storage backend")
+	}
+	if bucket == os.Getenv("S3_BUCKET") {
+		return nil, errors.New("S3_PRIVATE_BUCKET must differ from the public S3_BUCKET")
+	}
+	s3, err := openS3Bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	s3.publicURL = ""
+	return s3, nil
+}
+
+func openS3Bucket(bucket string) (*S3, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 storage backend")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+		Secure: os.Getenv("S3_USE_SSL") != "false",
+		Region: os.Getenv("S3_REGION"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach bucket %s: %w", bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", bucket)
+	}
+
+	// Defaults to path-style URLs on the endpoint, override for a CDN
+	publicURL := strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = client.EndpointURL().String() + "/" + bucket
+	}
+
+	return &S3{client: client, bucket: bucket, publicURL: publicURL}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, Stat surfaces missing keys
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		objects = append(objects, Object{Key: info.Key, Size: info.Size, LastModified: info.LastModified})
+	}
+	return objects, nil
+}
+
+func (s *S3) URL(key string) string {
+	if s.publicURL == "" {
+		return ""
+	}
+	return s.publicURL + "/" + key
+}
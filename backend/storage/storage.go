@@ -0,0 +1,135 @@
+// Package storage abstracts where uploaded files live. The database stores
+// object keys; public URLs are derived from the active backend.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored file as returned by List.
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context) ([]Object, error)
+	// URL returns the public URL of the object.
+	URL(key string) string
+}
+
+// Default is the backend used by the application, set by Init.
+var Default Storage
+
+// legacyUploadsURL is where uploads were served before storage backends
+// ($PUBLIC_BASE_URL/uploads/), still sent back by old clients.
+var legacyUploadsURL string
+
+// Private holds files that must only be served through authenticated
+// endpoints (handouts). It is never exposed under /uploads.
+var Private Storage
+
+// Init selects the backend from STORAGE_BACKEND ("local" or "s3").
+func Init() error {
+	legacyUploadsURL = ""
+	if baseURL := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"); baseURL != "" {
+		legacyUploadsURL = baseURL + "/uploads/"
+	}
+
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		baseURL := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+			log.Printf("WARNING: PUBLIC_BASE_URL not set, upload URLs will point to %s", baseURL)
+		}
+		Default = NewFilesystem(LocalDir(), baseURL+"/uploads")
+		Private = NewFilesystem(PrivateDir(), "")
+		log.Printf("Storage: local filesystem (%s)", LocalDir())
+	case "s3":
+		s3, err := NewS3FromEnv()
+		if err != nil {
+			return err
+		}
+		// Public uploads need a public-read bucket, so private files get their own
+		private, err := NewPrivateS3FromEnv()
+		if err != nil {
+			return err
+		}
+		Default = s3
+		Private = private
+		log.Printf("Storage: S3 buckets %s (public) and %s (private)", s3.bucket, private.bucket)
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+	return nil
+}
+
+// IsLocal reports whether uploads are kept on the local filesystem and must be served by us.
+func IsLocal() bool {
+	_, ok := Default.(*Filesystem)
+	return ok
+}
+
+// LocalDir is the directory used by the filesystem backend.
+func LocalDir() string {
+	if dir := os.Getenv("UPLOADS_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// PrivateDir is the directory used for private files by the filesystem backend.
+func PrivateDir() string {
+	if dir := os.Getenv("PRIVATE_UPLOADS_DIR"); dir != "" {
+		return dir
+	}
+	return "private_uploads"
+}
+
+// PublicURL turns a stored value into a URL for API responses. Values that
+// already are absolute URLs (external images) are returned untouched.
+func PublicURL(value string) string {
+	if value == "" || isAbsoluteURL(value) || Default == nil {
+		return value
+	}
+	return Default.URL(value)
+}
+
+// KeyFromURL is the inverse of PublicURL for values sent back by clients:
+// URLs pointing to our uploads become keys again, other URLs are kept.
+// Only our own base URLs are stripped, so a URL on another host cannot
+// claim the key of someone else's upload.
+func KeyFromURL(value string) string {
+	if value == "" || !isAbsoluteURL(value) {
+		return value
+	}
+	if Default != nil {
+		if base := Default.URL(""); base != "" && strings.HasPrefix(value, base) {
+			return strings.TrimPrefix(value, base)
+		}
+	}
+	if legacyUploadsURL != "" && strings.HasPrefix(value, legacyUploadsURL) {
+		if key := strings.TrimPrefix(value, legacyUploadsURL); key != "" && !strings.Contains(key, "/") {
+			return key
+		}
+	}
+	return value
+}
+
+func isAbsoluteURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
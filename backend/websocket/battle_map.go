@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+
+	model "questhub/models/database"
+	"questhub/service"
+)
+
+func marshalEvent(gameID, msgType string, payload any) []byte {
+	msgBytes, err := json.Marshal(map[string]any{
+		"type":    msgType,
+		"game_id": gameID,
+		"payload": payload,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s message: %v", msgType, err)
+		return nil
+	}
+	return msgBytes
+}
+
+// BroadcastBattleMap sends MAP_UPDATED with the map as each member may see it.
+// Players get MAP_DELETED instead when the map is not shown to them.
+func (h *Hub) BroadcastBattleMap(gameID, gmID string, m *model.BattleMap) {
+	full := marshalEvent(gameID, "MAP_UPDATED", m)
+	h.BroadcastToGameMembers(gameID, func(userID string) []byte {
+		if userID == gmID {
+			return full
+		}
+		filtered := service.BattleMapForPlayer(m, userID)
+		if filtered == nil {
+			return marshalEvent(gameID, "MAP_DELETED", map[string]string{"map_id": m.ID})
+		}
+		return marshalEvent(gameID, "MAP_UPDATED", filtered)
+	})
+}
+
+// BroadcastTokenChange tells each member how a token changed from their point
+// of view: a token leaving a player's sight is removed, one entering it is added.
+// previous is nil for new tokens, token is nil for removed ones.
+func (h *Hub) BroadcastTokenChange(gameID, gmID string, m *model.BattleMap, msgType string, token, previous *model.MapToken) {
+	h.BroadcastToGameMembers(gameID, func(userID string) []byte {
+		isGM := userID == gmID
+		visibleBefore := previous != nil && service.TokenVisibleTo(m, previous, userID, isGM)
+		visibleNow := token != nil && service.TokenVisibleTo(m, token, userID, isGM)
+
+		switch {
+		case visibleNow && isGM:
+			return marshalEvent(gameID, msgType, token)
+		case visibleNow && visibleBefore:
+			return marshalEvent(gameID, msgType, service.TokenForPlayer(token))
+		case visibleNow:
+			return marshalEvent(gameID, "TOKEN_ADDED", service.TokenForPlayer(token))
+		case visibleBefore:
+			return marshalEvent(gameID, "TOKEN_REMOVED", map[string]string{"map_id": previous.MapID, "token_id": previous.ID})
+		default:
+			return nil
+		}
+	})
+}
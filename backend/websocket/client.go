@@ -3,8 +3,13 @@ package websocket
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"questhub/config"
+	"questhub/middleware"
 	"questhub/models/database"
+	"questhub/ratelimit"
 	"questhub/service"
 	"time"
 
@@ -33,6 +38,17 @@ var (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	CheckOrigin:     checkOrigin,
+}
+
+// checkOrigin applies the same allowlist as the CORS middleware. Requests
+// without an Origin header do not come from a browser and are accepted.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return config.IsOriginAllowed(origin)
 }
 
 // Client is a middleman between the websocket connection and the hub.
@@ -47,6 +63,17 @@ type Client struct {
 
 	// UserID of the connected user
 	UserID string
+
+	// Set when the socket was opened with a personal API token
+	IsBot   bool
+	BotName string
+
+	// Whether the client may post chat messages and events
+	CanChat bool
+
+	// Limit on chat frames ("chat" policy), shared with the HTTP chat
+	// endpoint and every other socket of the user
+	chatLimiter *ratelimit.Store
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -79,20 +106,72 @@ func (c *Client) readPump() {
 			continue
 		}
 
+		// Everything else is emitted by the server after validation
+		msgType, _ := msgMap["type"].(string)
+		if !clientMessageTypes[msgType] {
+			c.sendError("Unknown message type.")
+			continue
+		}
+
 		// Handle Chat Messages
-		if msgType, ok := msgMap["type"].(string); ok && (msgType == "CHAT_GLOBAL" || msgType == "CHAT_PRIVATE" || msgType == "EVENT") {
+		if msgType == "CHAT_GLOBAL" || msgType == "CHAT_PRIVATE" || msgType == "EVENT" {
 			gameID, ok := msgMap["game_id"].(string)
 			if !ok || gameID == "" {
 				log.Printf("error: game_id missing in chat message")
 				continue
 			}
 
+			if result := c.chatLimiter.Allow(middleware.UserRateLimitKey(c.UserID)); !result.Allowed {
+				rateErr := middleware.NewRateLimitError("chat", result.RetryAfter)
+				errMsg := map[string]any{
+					"type":        "ERROR",
+					"content":     rateErr.Message,
+					"error":       rateErr.Error,
+					"policy":      rateErr.Policy,
+					"retry_after": rateErr.RetryAfter,
+				}
+				if jsonBytes, err := json.Marshal(errMsg); err == nil {
+					c.send <- jsonBytes
+				}
+				continue
+			}
+
+			if !c.CanChat {
+				errMsg := map[string]string{
+					"type":    "ERROR",
+					"content": "This token is not allowed to post in the chat.",
+				}
+				if jsonBytes, err := json.Marshal(errMsg); err == nil {
+					c.send <- jsonBytes
+				}
+				continue
+			}
+
 			// Check Game State
 			game, err := service.GetTable(gameID)
 			if err != nil {
 				log.Printf("error checking game state: %v", err)
 				continue
 			}
+			// Reject messages for games the user is not part of (e.g. after a kick or ban)
+			if game.GmID != c.UserID {
+				isMember, err := service.IsGameMember(gameID, c.UserID)
+				if err != nil {
+					log.Printf("error checking game membership: %v", err)
+					continue
+				}
+				if !isMember {
+					errMsg := map[string]string{
+						"type":    "ERROR",
+						"content": "You are not a member of this game.",
+					}
+					if jsonBytes, err := json.Marshal(errMsg); err == nil {
+						c.send <- jsonBytes
+					}
+					continue
+				}
+			}
+
 			if game.State == "paused" {
 				// Send error back to client
 				errMsg := map[string]string{
@@ -108,6 +187,11 @@ func (c *Client) readPump() {
 			// Enrich message with server-side data
 			msgMap["sender_id"] = c.UserID
 			msgMap["created_at"] = time.Now()
+			msgMap["is_bot"] = c.IsBot
+			if c.IsBot {
+				// Bots always speak under their token name
+				msgMap["sender_name"] = c.BotName
+			}
 			// SenderName should ideally be fetched from DB or context, for now assuming it's sent or we fetch it
 			// For simplicity, let's assume the frontend sends the sender_name for now, or we fetch it.
 			// Ideally, we should fetch the user to get the name to prevent spoofing.
@@ -128,6 +212,10 @@ func (c *Client) readPump() {
 				chatMsg.TargetID = &targetID
 			}
 
+			if c.IsBot {
+				chatMsg.IsBot = true
+			}
+
 			// Save to DB
 			if err := service.SaveMessage(chatMsg); err != nil {
 				log.Printf("error saving message: %v", err)
@@ -142,11 +230,79 @@ func (c *Client) readPump() {
 			// We need to re-marshal the map to include the updates (like timestamp)
 			updatedMessage, _ := json.Marshal(msgMap)
 			c.hub.broadcast <- updatedMessage
+		} else if msgType == "TOKEN_MOVE" {
+			c.handleTokenMove(msgMap)
+		}
+	}
+}
+
+// clientMessageTypes are the frames clients may send. They are checked and
+// rebroadcast by readPump; no other frame is relayed.
+var clientMessageTypes = map[string]bool{
+	"CHAT_GLOBAL":  true,
+	"CHAT_PRIVATE": true,
+	"EVENT":        true,
+	"TOKEN_MOVE":   true,
+}
+
+// sendError reports a rejected frame to the client.
+func (c *Client) sendError(content string) {
+	errMsg := map[string]string{
+		"type":    "ERROR",
+		"content": content,
+	}
+	if jsonBytes, err := json.Marshal(errMsg); err == nil {
+		c.send <- jsonBytes
+	}
+}
+
+// handleTokenMove applies a {"type":"TOKEN_MOVE","game_id","map_id","token_id","x","y"}
+// frame and broadcasts TOKEN_MOVED to the members who can see the token.
+func (c *Client) handleTokenMove(msgMap map[string]interface{}) {
+	gameID, _ := msgMap["game_id"].(string)
+	mapID, _ := msgMap["map_id"].(string)
+	tokenID, _ := msgMap["token_id"].(string)
+	x, okX := msgMap["x"].(float64)
+	y, okY := msgMap["y"].(float64)
+	if gameID == "" || mapID == "" || tokenID == "" || !okX || !okY {
+		c.sendError("Invalid token move.")
+		return
+	}
+
+	if c.IsBot {
+		c.sendError("Tokens cannot be moved with an API token.")
+		return
+	}
+
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		log.Printf("error checking game state: %v", err)
+		return
+	}
+	if game.GmID != c.UserID && game.State == "paused" {
+		c.sendError("Game is paused. Actions are restricted.")
+		return
+	}
+
+	// MoveToken checks that the user is the GM or the player of the token's character
+	token, previous, err := service.MoveToken(gameID, mapID, tokenID, c.UserID, int(x), int(y))
+	if err != nil {
+		if errors.Is(err, service.ErrTokenForbidden) || errors.Is(err, service.ErrTokenOutOfBounds) {
+			c.sendError(err.Error())
+		} else if err.Error() == "token not found" {
+			c.sendError("Token not found.")
 		} else {
-			// Unknown message type or not a chat message, just broadcast (e.g. character update is handled elsewhere)
-			c.hub.broadcast <- message
+			log.Printf("error moving token: %v", err)
 		}
+		return
+	}
+
+	battleMap, err := service.GetBattleMap(gameID, mapID)
+	if err != nil {
+		log.Printf("error fetching map for token broadcast: %v", err)
+		return
 	}
+	c.hub.BroadcastTokenChange(gameID, game.GmID, battleMap, "TOKEN_MOVED", token, previous)
 }
 
 // writePump pumps messages from the hub to the websocket connection.
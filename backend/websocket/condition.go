@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"log"
+
+	model "questhub/models/database"
+	"questhub/service"
+)
+
+// BroadcastCharacterConditions sends CONDITIONS_UPDATED with the current
+// conditions of a character to the GM and the character's player.
+func (h *Hub) BroadcastCharacterConditions(gameID, characterID string) {
+	game, err := service.GetTable(gameID)
+	if err != nil {
+		log.Printf("Error fetching game for conditions broadcast: %v", err)
+		return
+	}
+	char, err := service.GetCharacter(gameID, characterID)
+	if err != nil || char == nil {
+		return
+	}
+
+	msg := marshalEvent(gameID, "CONDITIONS_UPDATED", map[string]any{
+		"character_id": characterID,
+		"conditions":   char.Conditions,
+	})
+	h.BroadcastToGameMembers(gameID, func(userID string) []byte {
+		if userID == game.GmID || (char.UserID != nil && *char.UserID == userID) {
+			return msg
+		}
+		return nil
+	})
+}
+
+// BroadcastExpiredConditions notifies each affected character once.
+func (h *Hub) BroadcastExpiredConditions(expired []model.Condition) {
+	seen := map[string]bool{}
+	for _, c := range expired {
+		if seen[c.CharacterID] {
+			continue
+		}
+		seen[c.CharacterID] = true
+		h.BroadcastCharacterConditions(c.GameID, c.CharacterID)
+	}
+}
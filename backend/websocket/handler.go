@@ -3,6 +3,9 @@ package websocket
 import (
 	"log"
 	"net/http"
+	"questhub/middleware"
+	"questhub/ratelimit"
+	"questhub/service"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -10,11 +13,7 @@ import (
 
 // ServeWs handles websocket requests from the peer.
 func ServeWs(hub *Hub, c echo.Context) error {
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true // Allow all origins for now, configure as needed
-	}
-
-	// Extract UserID from JWT via context (set by middleware)
+	// Extract UserID from the ticket claims via context (set by middleware)
 	var userID string
 	claims, ok := c.Get("claims").(jwt.MapClaims)
 	if ok {
@@ -28,7 +27,13 @@ func ServeWs(hub *Hub, c echo.Context) error {
 		log.Println(err)
 		return err
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), UserID: userID}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), UserID: userID, CanChat: true}
+	client.chatLimiter = ratelimit.Get("chat")
+	if middleware.IsAPIToken(claims) {
+		client.IsBot = true
+		client.BotName = middleware.APITokenName(claims)
+		client.CanChat = middleware.HasScope(claims, service.ScopePostChat)
+	}
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
@@ -37,3 +42,21 @@ func ServeWs(hub *Hub, c echo.Context) error {
 	go client.readPump()
 	return nil
 }
+
+// IssueTicket returns a one-time ticket the client uses to open the socket.
+func IssueTicket(c echo.Context) error {
+	claims, ok := c.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token claims")
+	}
+
+	ticket, err := middleware.IssueWsTicket(claims)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to issue ticket").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"ticket":     ticket,
+		"expires_in": int(middleware.WsTicketTTL.Seconds()),
+	})
+}
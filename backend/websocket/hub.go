@@ -20,6 +20,33 @@ type Hub struct {
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// Kick requests removing a user from a game room.
+	kick chan kickRequest
+
+	// Game broadcasts rendered separately for each member.
+	rendered chan renderedBroadcast
+
+	// Messages for every connection of a single user.
+	direct chan directMessage
+}
+
+type directMessage struct {
+	userID  string
+	message []byte
+}
+
+// renderedBroadcast builds the message each member of a game receives.
+// render returns nil for users who must not receive anything.
+type renderedBroadcast struct {
+	gameID string
+	render func(userID string) []byte
+}
+
+type kickRequest struct {
+	gameID string
+	userID string
+	reason string
 }
 
 var GlobalHub *Hub
@@ -29,6 +56,9 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		kick:       make(chan kickRequest),
+		rendered:   make(chan renderedBroadcast),
+		direct:     make(chan directMessage),
 		clients:    make(map[*Client]bool),
 	}
 }
@@ -43,6 +73,45 @@ func (h *Hub) Run() {
 				delete(h.clients, client)
 				close(client.send)
 			}
+		case req := <-h.kick:
+			// Membership is already revoked in DB, so the user no longer
+			// receives game broadcasts. Tell their open sockets to leave the room.
+			msg := map[string]string{
+				"type":    "PLAYER_KICKED",
+				"game_id": req.gameID,
+				"reason":  req.reason,
+			}
+			msgBytes, err := json.Marshal(msg)
+			if err != nil {
+				log.Printf("error marshalling kick message: %v", err)
+				continue
+			}
+			h.deliverToUser(req.userID, msgBytes)
+		case msg := <-h.direct:
+			h.deliverToUser(msg.userID, msg.message)
+		case req := <-h.rendered:
+			players, err := service.GetGamePlayers(req.gameID)
+			if err != nil {
+				log.Printf("error fetching game players for broadcast: %v", err)
+				continue
+			}
+			messages := make(map[string][]byte, len(players))
+			for _, p := range players {
+				messages[p.UserID] = req.render(p.UserID)
+			}
+
+			for client := range h.clients {
+				message := messages[client.UserID]
+				if message == nil {
+					continue
+				}
+				select {
+				case client.send <- message:
+				default:
+					close(client.send)
+					delete(h.clients, client)
+				}
+			}
 		case message := <-h.broadcast:
 			// Parse message to get game_id and check if it's private
 			var msgMap map[string]any
@@ -59,10 +128,10 @@ func (h *Hub) Run() {
 			// but the logic here handles explicit targeting.
 			if msgType, ok := msgMap["type"].(string); ok && msgType == "CHAT_PRIVATE" {
 				if targetID, ok := msgMap["target_id"].(string); ok && targetID != "" {
-					h.BroadcastToUser(targetID, message)
+					h.deliverToUser(targetID, message)
 					// Also send back to sender so they see their own private message
 					if senderID, ok := msgMap["sender_id"].(string); ok && senderID != targetID {
-						h.BroadcastToUser(senderID, message)
+						h.deliverToUser(senderID, message)
 					}
 					continue
 				}
@@ -100,9 +169,9 @@ func (h *Hub) Run() {
 	}
 }
 
-// BroadcastToUser sends a message to a specific user.
-// This is a placeholder for more targeted broadcasting if needed.
-func (h *Hub) BroadcastToUser(userID string, message []byte) {
+// deliverToUser sends a message to a specific user. It mutates the client
+// set, so it must only run inside Run; other goroutines use SendToUser.
+func (h *Hub) deliverToUser(userID string, message []byte) {
 	for client := range h.clients {
 		if client.UserID == userID {
 			select {
@@ -115,6 +184,17 @@ func (h *Hub) BroadcastToUser(userID string, message []byte) {
 	}
 }
 
+// KickFromGame notifies every live connection of the user that they have
+// been removed from the game.
+func (h *Hub) KickFromGame(gameID, userID, reason string) {
+	h.kick <- kickRequest{gameID: gameID, userID: userID, reason: reason}
+}
+
+// SendToUser sends a message to every connection of the user.
+func (h *Hub) SendToUser(userID string, message []byte) {
+	h.direct <- directMessage{userID: userID, message: message}
+}
+
 // Broadcast sends a message to all clients.
 func (h *Hub) Broadcast(message []byte) {
 	h.broadcast <- message
@@ -132,3 +212,9 @@ func (h *Hub) BroadcastToGame(gameID string, msg interface{}) {
 	// The Controller creates `ChatMessage` which has `game_id` JSON tag.
 	h.broadcast <- bytes
 }
+
+// BroadcastToGameMembers sends each member of the game the message returned by
+// render for them. Used when members must not all see the same data.
+func (h *Hub) BroadcastToGameMembers(gameID string, render func(userID string) []byte) {
+	h.rendered <- renderedBroadcast{gameID: gameID, render: render}
+}
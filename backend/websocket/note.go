@@ -0,0 +1,24 @@
+package websocket
+
+import (
+	model "questhub/models/database"
+	"questhub/service"
+)
+
+// BroadcastNote sends NOTE_UPDATED to the members who can read the note.
+// Members who could read the previous version but no longer can get
+// NOTE_DELETED, so it disappears for them. previous is nil for a new note.
+func (h *Hub) BroadcastNote(gameID, gmID string, n, previous *model.Note) {
+	full := marshalEvent(gameID, "NOTE_UPDATED", n)
+	removed := marshalEvent(gameID, "NOTE_DELETED", map[string]string{"note_id": n.ID})
+	h.BroadcastToGameMembers(gameID, func(userID string) []byte {
+		isGM := userID == gmID
+		if service.CanReadNote(n, userID, isGM) {
+			return full
+		}
+		if previous != nil && service.CanReadNote(previous, userID, isGM) {
+			return removed
+		}
+		return nil
+	})
+}
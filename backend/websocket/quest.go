@@ -0,0 +1,17 @@
+package websocket
+
+import model "questhub/models/database"
+
+// BroadcastQuest sends QUEST_UPDATED to the GM, and to players when the quest
+// is revealed. Players get QUEST_DELETED instead when the quest is hidden, so
+// one hidden again disappears from their log.
+func (h *Hub) BroadcastQuest(gameID, gmID string, q *model.Quest) {
+	full := marshalEvent(gameID, "QUEST_UPDATED", q)
+	removed := marshalEvent(gameID, "QUEST_DELETED", map[string]string{"quest_id": q.ID})
+	h.BroadcastToGameMembers(gameID, func(userID string) []byte {
+		if userID == gmID || !q.Hidden {
+			return full
+		}
+		return removed
+	})
+}
@@ -34,10 +34,35 @@ export async function fetchHistory(gameId: string, token: string) {
     }
 }
 
-export function connectWebSocket(token: string) {
+// Exchange the bearer token for a one-time websocket ticket so the JWT never
+// appears in the socket URL.
+async function fetchWsTicket(token: string): Promise<string | null> {
+    try {
+        const response = await fetch(`${PUBLIC_BASE_API_URL}/ws/ticket`, {
+            method: 'POST',
+            headers: {
+                Authorization: `Bearer ${token}`
+            }
+        });
+        if (!response.ok) return null;
+        const data = await response.json();
+        return data.ticket ?? null;
+    } catch (e) {
+        console.error("Failed to fetch websocket ticket:", e);
+        return null;
+    }
+}
+
+export async function connectWebSocket(token: string) {
     if (socket?.readyState === WebSocket.OPEN) return;
 
-    const url = `${PUBLIC_BASE_WS_URL}/ws?token=${token}`;
+    const ticket = await fetchWsTicket(token);
+    if (!ticket) {
+        reconnectTimer = setTimeout(() => connectWebSocket(token), 3000);
+        return;
+    }
+
+    const url = `${PUBLIC_BASE_WS_URL}/ws?ticket=${ticket}`;
     socket = new WebSocket(url);
 
     socket.onopen = () => {
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Players banned from a game cannot send a new join request until the ban expires
+CREATE TABLE IF NOT EXISTS game_bans (
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    banned_by TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE, -- NULL means permanent
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (game_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS game_bans;
+-- +goose StatementEnd
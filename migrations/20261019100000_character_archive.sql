@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Characters of players who left the game can be archived instead of left unassigned
+ALTER TABLE game_characters ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE game_characters DROP COLUMN IF EXISTS archived_at;
+-- +goose StatementEnd
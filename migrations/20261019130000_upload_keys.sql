@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Uploads are now referenced by storage key, the public URL is derived at read time
+UPDATE games
+SET image_url = substring(image_url FROM '/uploads/([^/]+)$')
+WHERE image_url ~ '^https?://.*/uploads/[^/]+$';
+
+UPDATE characters
+SET avatar_url = substring(avatar_url FROM '/uploads/([^/]+)$')
+WHERE avatar_url ~ '^https?://.*/uploads/[^/]+$';
+
+UPDATE characters c
+SET inventory = (
+    SELECT jsonb_agg(
+        CASE
+            WHEN item->>'image_url' ~ '^https?://.*/uploads/[^/]+$'
+            THEN jsonb_set(item, '{image_url}', to_jsonb(substring(item->>'image_url' FROM '/uploads/([^/]+)$')))
+            ELSE item
+        END
+        ORDER BY ord
+    )
+    FROM jsonb_array_elements(c.inventory) WITH ORDINALITY AS t(item, ord)
+)
+WHERE jsonb_typeof(c.inventory) = 'array'
+  AND jsonb_array_length(c.inventory) > 0
+  AND c.inventory::text LIKE '%/uploads/%';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Keys resolve to the same files, the original hosts cannot be restored
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounting of stored uploads, used for quotas and deduplication.
+-- size covers the re-encoded original and all its thumbnails.
+CREATE TABLE IF NOT EXISTS uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,
+    content_hash TEXT NOT NULL, -- SHA-256 of the uploaded bytes
+    size BIGINT NOT NULL,
+    reference_count INTEGER NOT NULL DEFAULT 0, -- Refreshed by the upload GC
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, content_hash, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_owner_id ON uploads(owner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS uploads;
+-- +goose StatementEnd
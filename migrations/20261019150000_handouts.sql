@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GM handouts. Files live in private storage and are served through an
+-- authenticated endpoint once revealed.
+CREATE TABLE IF NOT EXISTS handouts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    storage_key TEXT NOT NULL,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    revealed_to_all BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revealed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_handouts_game_id ON handouts(game_id);
+
+-- Players a handout was revealed to when not revealed to everyone
+CREATE TABLE IF NOT EXISTS handout_reveals (
+    handout_id UUID NOT NULL REFERENCES handouts(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    revealed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (handout_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS handout_reveals;
+DROP TABLE IF EXISTS handouts;
+-- +goose StatementEnd
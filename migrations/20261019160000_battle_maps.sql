@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Battle maps: a background image with a grid, measured in cells
+CREATE TABLE IF NOT EXISTS battle_maps (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    background_url TEXT, -- Storage key of an upload, or an external URL
+    grid_size INTEGER NOT NULL DEFAULT 50, -- Cell size in pixels on the background
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT FALSE, -- The map currently shown to players
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_battle_maps_game_id ON battle_maps(game_id);
+
+-- Tokens placed on a map, optionally linked to a character of the game
+CREATE TABLE IF NOT EXISTS map_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    map_id UUID NOT NULL REFERENCES battle_maps(id) ON DELETE CASCADE,
+    character_id UUID REFERENCES characters(id) ON DELETE CASCADE,
+    label TEXT NOT NULL DEFAULT '',
+    x INTEGER NOT NULL DEFAULT 0,
+    y INTEGER NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL DEFAULT 1, -- Cells covered on each side
+    color TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_map_tokens_map_id ON map_tokens(map_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS map_tokens;
+DROP TABLE IF EXISTS battle_maps;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Fog of war: when enabled, players only see revealed cells.
+-- revealed_cells is a row-major bitmap, bit (y * width + x), least significant bit first.
+ALTER TABLE battle_maps ADD COLUMN IF NOT EXISTS fog_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE battle_maps ADD COLUMN IF NOT EXISTS revealed_cells BYTEA NOT NULL DEFAULT '';
+
+-- Hidden tokens (invisible monsters, traps...) are only sent to the GM and the listed players
+ALTER TABLE map_tokens ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE map_tokens ADD COLUMN IF NOT EXISTS visible_to TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE map_tokens DROP COLUMN IF EXISTS visible_to;
+ALTER TABLE map_tokens DROP COLUMN IF EXISTS hidden;
+ALTER TABLE battle_maps DROP COLUMN IF EXISTS revealed_cells;
+ALTER TABLE battle_maps DROP COLUMN IF EXISTS fog_enabled;
+-- +goose StatementEnd
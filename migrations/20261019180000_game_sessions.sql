@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Scheduled play sessions. Cancelled sessions are kept so calendar feeds can report them.
+CREATE TABLE IF NOT EXISTS game_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    agenda TEXT NOT NULL DEFAULT '',
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_minutes INTEGER NOT NULL DEFAULT 180,
+    created_by TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    cancelled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_sessions_game_id ON game_sessions(game_id, scheduled_at);
+
+CREATE TABLE IF NOT EXISTS session_rsvps (
+    session_id UUID NOT NULL REFERENCES game_sessions(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('yes', 'no', 'maybe')),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_rsvps;
+DROP TABLE IF EXISTS game_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sessions are started and ended when the GM switches the game to ongoing or paused
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS recap TEXT NOT NULL DEFAULT '';
+
+-- Messages sent while a session is running belong to it
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS session_id UUID REFERENCES game_sessions(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_session_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS session_id;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS recap;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS ended_at;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS started_at;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS quests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    giver_id UUID REFERENCES characters(id) ON DELETE SET NULL, -- NPC who gave the quest
+    status TEXT NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'active', 'completed', 'failed')),
+    hidden BOOLEAN NOT NULL DEFAULT TRUE, -- Players only see quests the GM revealed
+    reward_xp INTEGER NOT NULL DEFAULT 0,
+    reward_money INTEGER NOT NULL DEFAULT 0,
+    reward_items JSONB NOT NULL DEFAULT '[]', -- Inventory items, same shape as characters.inventory
+    rewarded_at TIMESTAMP WITH TIME ZONE, -- Set once rewards were given, they are never given twice
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quests_game_id ON quests(game_id);
+
+CREATE TABLE IF NOT EXISTS quest_objectives (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    quest_id UUID NOT NULL REFERENCES quests(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_quest_objectives_quest_id ON quest_objectives(quest_id);
+
+-- Characters receiving the rewards
+CREATE TABLE IF NOT EXISTS quest_characters (
+    quest_id UUID NOT NULL REFERENCES quests(id) ON DELETE CASCADE,
+    character_id UUID NOT NULL REFERENCES characters(id) ON DELETE CASCADE,
+    PRIMARY KEY (quest_id, character_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quest_characters;
+DROP TABLE IF EXISTS quest_objectives;
+DROP TABLE IF EXISTS quests;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Experience needed for each level, starting at level 1. Defaults to the 5e table.
+ALTER TABLE games ADD COLUMN IF NOT EXISTS level_thresholds INTEGER[] NOT NULL
+    DEFAULT '{0,300,900,2700,6500,14000,23000,34000,48000,64000,85000,100000,120000,140000,165000,195000,225000,265000,305000,355000}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games DROP COLUMN IF EXISTS level_thresholds;
+-- +goose StatementEnd
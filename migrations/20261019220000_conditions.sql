@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Current combat round of the game, 0 outside of combat
+ALTER TABLE games ADD COLUMN IF NOT EXISTS combat_round INTEGER NOT NULL DEFAULT 0;
+
+-- Conditions affecting a character (poisoned, stunned, blessed...)
+CREATE TABLE IF NOT EXISTS character_conditions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    character_id UUID NOT NULL REFERENCES characters(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    modifiers JSONB NOT NULL DEFAULT '{}', -- e.g. {"armor_class": -2, "speed": -10}
+    duration_type TEXT NOT NULL DEFAULT 'permanent' CHECK (duration_type IN ('rounds', 'minutes', 'permanent')),
+    duration INTEGER NOT NULL DEFAULT 0,
+    expires_round INTEGER, -- Combat round at which a rounds condition ends
+    expires_at TIMESTAMP WITH TIME ZONE, -- End of a minutes condition
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_character_conditions_character_id ON character_conditions(character_id);
+CREATE INDEX IF NOT EXISTS idx_character_conditions_expires_at ON character_conditions(expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS character_conditions;
+ALTER TABLE games DROP COLUMN IF EXISTS combat_round;
+-- +goose StatementEnd
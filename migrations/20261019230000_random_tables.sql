@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Random tables rolled by the GM (loot, encounters, names...)
+CREATE TABLE IF NOT EXISTS random_tables (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    dice TEXT NOT NULL DEFAULT '', -- e.g. "d100" or "2d6", empty for weighted entries
+    shared BOOLEAN NOT NULL DEFAULT FALSE, -- Listed in the library other GMs can import from
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_random_tables_game_id ON random_tables(game_id);
+CREATE INDEX IF NOT EXISTS idx_random_tables_shared ON random_tables(shared) WHERE shared;
+
+CREATE TABLE IF NOT EXISTS random_table_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_id UUID NOT NULL REFERENCES random_tables(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    text TEXT NOT NULL DEFAULT '',
+    weight INTEGER NOT NULL DEFAULT 1, -- Used by weighted tables
+    range_min INTEGER, -- Dice results covered, used by dice tables
+    range_max INTEGER,
+    table_ref UUID REFERENCES random_tables(id) ON DELETE SET NULL, -- Table rolled next
+    item JSONB -- Loot item, same shape as characters.inventory entries
+);
+
+CREATE INDEX IF NOT EXISTS idx_random_table_entries_table_id ON random_table_entries(table_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS random_table_entries;
+DROP TABLE IF EXISTS random_tables;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Shared money and items of the party, one stash per game
+CREATE TABLE IF NOT EXISTS party_stashes (
+    game_id UUID PRIMARY KEY REFERENCES games(id) ON DELETE CASCADE,
+    money INTEGER NOT NULL DEFAULT 0 CHECK (money >= 0),
+    items JSONB NOT NULL DEFAULT '[]', -- Inventory items, each with an "id"
+    free_withdraw BOOLEAN NOT NULL DEFAULT FALSE, -- Players may withdraw without the GM
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every movement in or out of the stash
+CREATE TABLE IF NOT EXISTS stash_ledger (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL,
+    character_id UUID REFERENCES characters(id) ON DELETE SET NULL,
+    character_name TEXT, -- Kept when the character is deleted
+    action TEXT NOT NULL CHECK (action IN ('deposit', 'withdraw')),
+    money INTEGER NOT NULL DEFAULT 0,
+    items JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stash_ledger_game_id ON stash_ledger(game_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stash_ledger;
+DROP TABLE IF EXISTS party_stashes;
+-- +goose StatementEnd
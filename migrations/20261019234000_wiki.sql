@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Campaign wiki pages written by the GM in Markdown
+CREATE TABLE IF NOT EXISTS wiki_pages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    slug TEXT NOT NULL, -- Target of [[Title]] links
+    category TEXT NOT NULL DEFAULT 'general' CHECK (category IN ('general', 'location', 'npc', 'faction', 'history')),
+    content TEXT NOT NULL DEFAULT '',
+    public_content TEXT NOT NULL DEFAULT '', -- Content without the GM secret sections
+    created_by TEXT NOT NULL,
+    updated_by TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    search_full TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || content)) STORED,
+    search_public TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || public_content)) STORED,
+    UNIQUE (game_id, slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wiki_pages_search_full ON wiki_pages USING GIN (search_full);
+CREATE INDEX IF NOT EXISTS idx_wiki_pages_search_public ON wiki_pages USING GIN (search_public);
+
+-- [[links]] found in each page, to compute backlinks
+CREATE TABLE IF NOT EXISTS wiki_links (
+    page_id UUID NOT NULL REFERENCES wiki_pages(id) ON DELETE CASCADE,
+    target_slug TEXT NOT NULL,
+    secret BOOLEAN NOT NULL DEFAULT FALSE, -- Only found in a GM secret section
+    PRIMARY KEY (page_id, target_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wiki_links_target_slug ON wiki_links(target_slug);
+
+-- Every saved version of a page
+CREATE TABLE IF NOT EXISTS wiki_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id UUID NOT NULL REFERENCES wiki_pages(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    category TEXT NOT NULL,
+    content TEXT NOT NULL,
+    edited_by TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_wiki_revisions_page_id ON wiki_revisions(page_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS wiki_revisions;
+DROP TABLE IF EXISTS wiki_links;
+DROP TABLE IF EXISTS wiki_pages;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Many titled notes per user and game, instead of one note each.
+-- Existing notes stay readable by the GM as before.
+ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_pkey;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE notes ADD PRIMARY KEY (id);
+
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT 'Notes';
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS folder TEXT NOT NULL DEFAULT ''; -- Slash separated path, e.g. "NPCs/Villains"
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'gm' CHECK (visibility IN ('private', 'gm', 'party'));
+ALTER TABLE notes ALTER COLUMN visibility SET DEFAULT 'private';
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE notes SET content = '' WHERE content IS NULL;
+ALTER TABLE notes ALTER COLUMN content SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notes_game_user ON notes(game_id, user_id);
+
+-- Members a note is shared with, on top of its visibility
+CREATE TABLE IF NOT EXISTS note_shares (
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_shares_user_id ON note_shares(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS note_shares;
+
+-- Only the first note of each user survives
+DELETE FROM notes a USING notes b
+WHERE a.game_id = b.game_id AND a.user_id = b.user_id AND (a.created_at, a.id) > (b.created_at, b.id);
+
+ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_pkey;
+DROP INDEX IF EXISTS idx_notes_game_user;
+ALTER TABLE notes
+    DROP COLUMN IF EXISTS id,
+    DROP COLUMN IF EXISTS title,
+    DROP COLUMN IF EXISTS folder,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS visibility,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE notes ALTER COLUMN content DROP NOT NULL;
+ALTER TABLE notes ADD PRIMARY KEY (game_id, user_id);
+-- +goose StatementEnd
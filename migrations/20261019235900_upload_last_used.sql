@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bumped when a deduplicated upload is reused, so the GC grace period
+-- protects it even though the stored file is old.
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+UPDATE uploads SET last_used_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE uploads DROP COLUMN IF EXISTS last_used_at;
+-- +goose StatementEnd